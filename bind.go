@@ -0,0 +1,200 @@
+package udp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+)
+
+// Set of bind failure classes, matched against a *BindError with errors.Is.
+var (
+	ErrBindAddrInUse        = errors.New("Address Already In Use")
+	ErrBindAddrNotAvailable = errors.New("Address Not Available")
+	ErrBindPermission       = errors.New("Permission Denied")
+)
+
+// BindError describes a failure to bind the listener's address.
+type BindError struct {
+	NetType string
+	Addr    string
+	Kind    error // One of the ErrBind classes, nil when unclassified.
+	Err     error
+
+	port int
+}
+
+// Error implements the error interface.
+func (be *BindError) Error() string {
+	msg := fmt.Sprintf("bind %s %s : %v", be.NetType, be.Addr, be.Err)
+
+	if be.Kind == ErrBindPermission && be.port > 0 && be.port < 1024 {
+		msg += " : ports below 1024 require elevated privileges"
+	}
+
+	return msg
+}
+
+// Unwrap returns the underlying error from the net package.
+func (be *BindError) Unwrap() error {
+	return be.Err
+}
+
+// Is reports whether the failure belongs to the target class.
+func (be *BindError) Is(target error) bool {
+	return be.Kind != nil && be.Kind == target
+}
+
+// bufferSetter is implemented by connections whose kernel buffers can be
+// sized, such as *net.UDPConn.
+type bufferSetter interface {
+	SetReadBuffer(bytes int) error
+	SetWriteBuffer(bytes int) error
+}
+
+// bind opens the listener, or takes the connection provided by the user,
+// and asks the user to bind the reader and writer they want to use for it.
+// The caller must hold the listener lock.
+func (d *UDP) bind() error {
+	var listener net.PacketConn = d.Conn
+	if listener == nil {
+		conn, err := d.listen(d.udpAddr)
+		if err != nil {
+			return d.bindError(err)
+		}
+		listener = conn
+	}
+
+	if err := d.sizeBuffers(listener); err != nil {
+		listener.Close()
+		return err
+	}
+
+	d.listener = listener
+	d.reader, d.writer = d.bindConn(listener)
+
+	d.Event("accept", "Waiting For Data : IPAddress[ %s ]", join(d.ipAddress, d.port))
+
+	return nil
+}
+
+// bindConn asks the user to bind the reader and writer for the connection,
+// through BindPacketConn when it is not a *net.UDPConn.
+func (d *UDP) bindConn(listener net.PacketConn) (io.Reader, io.Writer) {
+	if conn, ok := listener.(*net.UDPConn); ok {
+		return d.ConnHandler.Bind(conn)
+	}
+
+	return d.ConnHandler.(PacketConnHandler).BindPacketConn(listener)
+}
+
+// bindError wraps a failure to open the socket for the configured address.
+func (d *UDP) bindError(err error) *BindError {
+	return &BindError{
+		NetType: d.NetType,
+		Addr:    join(d.ipAddress, d.port),
+		Kind:    bindKind(err),
+		Err:     err,
+
+		port: d.port,
+	}
+}
+
+// sizeBuffers sizes the kernel buffers of the socket.
+func (d *UDP) sizeBuffers(listener net.PacketConn) error {
+	bs, ok := listener.(bufferSetter)
+	if !ok {
+		return nil
+	}
+
+	if d.ReadBufferSize > 0 {
+		if err := bs.SetReadBuffer(d.ReadBufferSize); err != nil {
+			return err
+		}
+	}
+
+	if d.WriteBufferSize > 0 {
+		if err := bs.SetWriteBuffer(d.WriteBufferSize); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// listen opens the socket for the address.
+func (d *UDP) listen(udpAddr *net.UDPAddr) (*net.UDPConn, error) {
+	conn, err := d.open(udpAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.Broadcast {
+		if err := setBroadcast(conn); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	if err := d.setSockopts(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// open opens a unicast socket, or one joined to the multicast group.
+func (d *UDP) open(udpAddr *net.UDPAddr) (*net.UDPConn, error) {
+	if d.MulticastGroup != "" {
+		var ifi *net.Interface
+		if d.MulticastInterface != "" {
+			var err error
+			if ifi, err = net.InterfaceByName(d.MulticastInterface); err != nil {
+				return nil, err
+			}
+		}
+
+		gaddr := net.UDPAddr{
+			IP:   net.ParseIP(d.MulticastGroup),
+			Port: d.port,
+		}
+
+		return net.ListenMulticastUDP(d.NetType, ifi, &gaddr)
+	}
+
+	if !d.FreeBind && d.ReusePort <= 1 {
+		return net.ListenUDP(d.NetType, udpAddr)
+	}
+
+	lc := net.ListenConfig{
+		Control: d.control,
+	}
+
+	conn, err := lc.ListenPacket(context.Background(), d.NetType, udpAddr.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return conn.(*net.UDPConn), nil
+}
+
+// control sets the socket options that must be in place before the socket
+// is bound.
+func (d *UDP) control(network, address string, c syscall.RawConn) error {
+	if d.FreeBind {
+		if err := freeBind(network, address, c); err != nil {
+			return err
+		}
+	}
+
+	if d.ReusePort > 1 {
+		if err := reusePort(network, address, c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package udp
+
+import (
+	"sync"
+	"time"
+)
+
+// bucket is a token bucket refilled at rate tokens per second, holding at
+// most burst tokens.
+type bucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	mu     sync.Mutex
+}
+
+// newBucket creates a full bucket. A burst of zero allows one second
+// worth of tokens.
+func newBucket(rate float64, burst float64) *bucket {
+	if burst <= 0 {
+		burst = rate
+	}
+
+	return &bucket{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// refill adds the tokens earned since the last call. The caller must hold
+// the lock.
+func (b *bucket) refill(now time.Time) {
+	if now.Before(b.last) {
+		return
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+}
+
+// full reports whether the bucket has refilled completely.
+func (b *bucket) full(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(now)
+
+	return b.tokens >= b.burst
+}
+
+// take removes n tokens and reports true when they are available. A full
+// bucket always allows the take, so a request larger than the burst is
+// not refused forever.
+func (b *bucket) take(n float64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(time.Now())
+
+	if b.tokens < n && b.tokens < b.burst {
+		return false
+	}
+
+	b.tokens -= n
+	return true
+}
+
+// reserve removes n tokens, borrowing against future refills when they
+// are not available, and returns how long the caller must wait before
+// the borrowed tokens have been earned.
+func (b *bucket) reserve(n float64) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(time.Now())
+
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
@@ -0,0 +1,297 @@
+package udp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// Set of error variables for the client.
+var (
+	ErrInvalidRetry   = errors.New("Invalid Client Retry Configuration")
+	ErrRequestTimeout = errors.New("Timed Out Waiting For A Response")
+	ErrClientClosed   = errors.New("Client Is Closed")
+)
+
+// defaultTimeout is how long Request waits for a response by default.
+const defaultTimeout = time.Second
+
+// ClientConfig provides a data structure of required configuration
+// parameters for a client. Unlike Config it takes no handlers, since the
+// client reads only from the listener it dialed and returns each response
+// to the caller of Request. Match is where a protocol plugs in.
+type ClientConfig struct {
+	NetType string // "udp", udp4" or "udp6"
+	Addr    string // Address of the listener, "host:port".
+
+	// *************************************************************************
+	// ** Not Required, optional                                              **
+	// *************************************************************************
+
+	OptEvent
+
+	// Timeout bounds how long Request waits for the response to each
+	// attempt, one second by default.
+	Timeout time.Duration
+
+	// Retries is the number of times Request sends the data again after an
+	// attempt timed out. Before each retry it waits Backoff, doubled after
+	// every retry.
+	Retries int
+	Backoff time.Duration
+
+	// Match reports whether the response answers the request, so concurrent
+	// requests get their own response. A response no pending request
+	// matches is discarded. When nil, each response answers the oldest
+	// pending request.
+	Match func(req []byte, resp []byte) bool
+
+	// MaxSize is the largest response read, MaxIPv4DatagramSize by default.
+	MaxSize int
+}
+
+// Validate checks the configuration to required items.
+func (cfg *ClientConfig) Validate() error {
+	if cfg == nil {
+		return ErrInvalidConfiguration
+	}
+
+	if cfg.NetType != "udp" && cfg.NetType != "udp4" && cfg.NetType != "udp6" {
+		return ErrInvalidNetType
+	}
+
+	if cfg.Timeout < 0 || cfg.Retries < 0 || cfg.Backoff < 0 {
+		return ErrInvalidRetry
+	}
+
+	if cfg.MaxSize < 0 || cfg.MaxSize > MaxIPv6DatagramSize {
+		return ErrInvalidMaxSize
+	}
+
+	return nil
+}
+
+// Event fires events back to the user for important events.
+func (cfg *ClientConfig) Event(event string, format string, a ...interface{}) {
+	if cfg.OptEvent.Event != nil {
+		cfg.OptEvent.Event(event, format, a...)
+	}
+}
+
+// pending is a request waiting for its response.
+type pending struct {
+	data []byte
+	resp chan []byte
+}
+
+// Client sends datagrams to a listener and correlates its responses to
+// the requests that caused them.
+type Client struct {
+	ClientConfig
+	Name string
+
+	conn *net.UDPConn
+
+	waiting []*pending
+	closed  bool
+	mu      sync.Mutex
+
+	done chan struct{}
+}
+
+// NewClient creates a client connected to the listener's address.
+func NewClient(name string, cfg ClientConfig) (*Client, error) {
+
+	// Validate the configuration.
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	udpAddr, err := net.ResolveUDPAddr(cfg.NetType, cfg.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.DialUDP(cfg.NetType, nil, udpAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	c := Client{
+		ClientConfig: cfg,
+		Name:         name,
+
+		conn: conn,
+		done: make(chan struct{}),
+	}
+
+	go c.read()
+
+	return &c, nil
+}
+
+// read hands each response to the pending request it answers until the
+// connection is closed.
+func (c *Client) read() {
+	defer close(c.done)
+
+	size := c.MaxSize
+	if size == 0 {
+		size = MaxIPv4DatagramSize
+	}
+
+	buf := make([]byte, size)
+	for {
+		n, err := c.conn.Read(buf)
+		if err != nil {
+			c.mu.Lock()
+			closed := c.closed
+			c.mu.Unlock()
+
+			if closed {
+				return
+			}
+
+			// Refused responses are reported by the next read on a
+			// connected socket and do not stop the client.
+			if isTemporary(err) || isRefused(err) {
+				c.Event("read", "ERROR : %v", err)
+				continue
+			}
+
+			c.Event("read", "ERROR : %v : Closing", err)
+			c.Close()
+			return
+		}
+
+		resp := make([]byte, n)
+		copy(resp, buf[:n])
+
+		if !c.deliver(resp) {
+			c.Event("read", "Unmatched Response Discarded : Length[ %d ]", n)
+		}
+	}
+}
+
+// deliver hands the response to the first pending request it matches.
+func (c *Client) deliver(resp []byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, p := range c.waiting {
+		if c.Match == nil || c.Match(p.data, resp) {
+			c.waiting = append(c.waiting[:i], c.waiting[i+1:]...)
+			p.resp <- resp
+			return true
+		}
+	}
+
+	return false
+}
+
+// Send writes the data to the listener without waiting for a response.
+func (c *Client) Send(data []byte) error {
+	_, err := c.conn.Write(data)
+	return err
+}
+
+// Request writes the data to the listener and returns the response that
+// answers it. An attempt without a response within Timeout is retried up
+// to Retries times, after which ErrRequestTimeout is returned. The
+// context can cancel the request early.
+func (c *Client) Request(ctx context.Context, data []byte) ([]byte, error) {
+	p := pending{
+		data: data,
+		resp: make(chan []byte, 1),
+	}
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, ErrClientClosed
+	}
+	c.waiting = append(c.waiting, &p)
+	c.mu.Unlock()
+
+	defer c.forget(&p)
+
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+	backoff := c.Backoff
+
+	for attempt := 0; ; attempt++ {
+		if err := c.Send(data); err != nil {
+			return nil, err
+		}
+
+		timer := time.NewTimer(timeout)
+		select {
+		case resp := <-p.resp:
+			timer.Stop()
+			return resp, nil
+		case <-c.done:
+			timer.Stop()
+			return nil, ErrClientClosed
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		if attempt == c.Retries {
+			return nil, ErrRequestTimeout
+		}
+
+		c.Event("request", "Retrying : Attempt[ %d ] Backoff[ %v ]", attempt+1, backoff)
+
+		if backoff > 0 {
+			timer := time.NewTimer(backoff)
+			select {
+			case <-c.done:
+				timer.Stop()
+				return nil, ErrClientClosed
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			case <-timer.C:
+			}
+			backoff *= 2
+		}
+	}
+}
+
+// forget removes the request from the pending requests if its response
+// never arrived.
+func (c *Client) forget(p *pending) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, w := range c.waiting {
+		if w == p {
+			c.waiting = append(c.waiting[:i], c.waiting[i+1:]...)
+			return
+		}
+	}
+}
+
+// LocalAddr returns the local address of the client.
+func (c *Client) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+// Close closes the connection. Pending requests return ErrClientClosed.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.mu.Unlock()
+
+	return c.conn.Close()
+}
@@ -0,0 +1,191 @@
+package udp_test
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/udp"
+)
+
+// TestClient validates a client receives the response to its request.
+func TestClient(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	t.Log("Given the need to make a request to a listener.")
+	{
+		u := newTestUDP(t)
+		if err := u.Start(); err != nil {
+			t.Fatal("\tShould be able to start the UDP listener.", failed, err)
+		}
+		defer u.Stop()
+
+		c, err := udp.NewClient("TEST", udp.ClientConfig{
+			NetType: "udp4",
+			Addr:    u.Addr().String(),
+		})
+		if err != nil {
+			t.Fatal("\tShould be able to create a new client.", failed, err)
+		}
+		defer c.Close()
+		t.Log("\tShould be able to create a new client.", success)
+
+		resp, err := c.Request(context.Background(), make([]byte, 20))
+		if err != nil || string(resp) != "GOT IT" {
+			t.Fatalf("\tShould receive the response : %q %v %s", resp, err, failed)
+		}
+		t.Log("\tShould receive the response.", success)
+	}
+}
+
+// TestClientRetry validates a request is sent again until it is answered
+// or the retries run out.
+func TestClientRetry(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	t.Log("Given the need to retry a request that got no response.")
+	{
+		var attempts int32
+
+		cfg := udp.Config{
+			NetType: "udp4",
+			Addr:    "127.0.0.1:0",
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler: funcReqHandler{
+				process: func(r *udp.Request) {
+
+					// Ignore the first attempt.
+					if atomic.AddInt32(&attempts, 1) == 1 {
+						return
+					}
+					udpReqHandler{}.Process(r)
+				},
+			},
+			RespHandler: udpRespHandler{},
+		}
+
+		u, err := udp.New("TEST", cfg)
+		if err != nil {
+			t.Fatal("\tShould be able to create a new UDP listener.", failed, err)
+		}
+
+		if err := u.Start(); err != nil {
+			t.Fatal("\tShould be able to start the UDP listener.", failed, err)
+		}
+		defer u.Stop()
+
+		tests := []struct {
+			retries int
+			err     error
+		}{
+			{0, udp.ErrRequestTimeout},
+			{1, nil},
+		}
+
+		for _, tt := range tests {
+			atomic.StoreInt32(&attempts, 0)
+
+			c, err := udp.NewClient("TEST", udp.ClientConfig{
+				NetType: "udp4",
+				Addr:    u.Addr().String(),
+				Timeout: 100 * time.Millisecond,
+				Retries: tt.retries,
+				Backoff: 10 * time.Millisecond,
+			})
+			if err != nil {
+				t.Fatal("\tShould be able to create a new client.", failed, err)
+			}
+
+			_, err = c.Request(context.Background(), make([]byte, 20))
+			c.Close()
+
+			if err != tt.err {
+				t.Errorf("\tShould get %v with %d retries : %v %s", tt.err, tt.retries, err, failed)
+				continue
+			}
+			t.Logf("\tShould get %v with %d retries. %s", tt.err, tt.retries, success)
+		}
+	}
+}
+
+// TestClientMatch validates concurrent requests each receive the response
+// that matches them.
+func TestClientMatch(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	t.Log("Given the need to correlate responses to concurrent requests.")
+	{
+		cfg := udp.Config{
+			NetType: "udp4",
+			Addr:    "127.0.0.1:0",
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler: funcReqHandler{
+				process: func(r *udp.Request) {
+
+					// Answer the requests in the reverse order they arrive.
+					if r.Data[0] == 0 {
+						time.Sleep(50 * time.Millisecond)
+					}
+					r.Reply(r.Data[:1])
+				},
+			},
+			RespHandler: udpRespHandler{},
+
+			MaxWorkers: 2,
+		}
+
+		u, err := udp.New("TEST", cfg)
+		if err != nil {
+			t.Fatal("\tShould be able to create a new UDP listener.", failed, err)
+		}
+
+		if err := u.Start(); err != nil {
+			t.Fatal("\tShould be able to start the UDP listener.", failed, err)
+		}
+		defer u.Stop()
+
+		c, err := udp.NewClient("TEST", udp.ClientConfig{
+			NetType: "udp4",
+			Addr:    u.Addr().String(),
+			Match: func(req []byte, resp []byte) bool {
+				return bytes.Equal(req[:1], resp)
+			},
+		})
+		if err != nil {
+			t.Fatal("\tShould be able to create a new client.", failed, err)
+		}
+		defer c.Close()
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+
+		for id := byte(0); id < 2; id++ {
+			go func(id byte) {
+				defer wg.Done()
+
+				req := make([]byte, 20)
+				req[0] = id
+
+				resp, err := c.Request(context.Background(), req)
+				if err != nil || len(resp) != 1 || resp[0] != id {
+					t.Errorf("\tShould receive the response to request %d : %v %v %s", id, resp, err, failed)
+					return
+				}
+				t.Logf("\tShould receive the response to request %d. %s", id, success)
+			}(id)
+
+			// Make sure the slow request is read first.
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		wg.Wait()
+	}
+}
@@ -0,0 +1,160 @@
+package udp
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CombineStat reports the work of the write combiner.
+type CombineStat struct {
+	Responses int64 // Responses handed to Send.
+	Datagrams int64 // Combined datagrams written.
+}
+
+// combined holds the framed responses waiting for one destination.
+type combined struct {
+	udpAddr *net.UDPAddr
+	data    []byte
+}
+
+// combiner batches the responses for each destination and writes them as
+// a single framed datagram every interval.
+type combiner struct {
+	d       *UDP
+	maxSize int
+
+	pending map[string]*combined
+	closed  bool
+	mu      sync.Mutex
+
+	responses int64
+	datagrams int64
+
+	shutdown chan struct{}
+	done     chan struct{}
+}
+
+// newCombiner creates a combiner for the listener.
+func newCombiner(d *UDP) *combiner {
+	return &combiner{
+		d:        d,
+		maxSize:  combineMaxSize(d.CombineInterval, d.CombineMaxSize),
+		pending:  make(map[string]*combined),
+		shutdown: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// start launches the routine flushing the pending responses every interval.
+func (c *combiner) start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				c.flush()
+			case <-c.shutdown:
+				c.flush()
+				close(c.done)
+				return
+			}
+		}
+	}()
+}
+
+// stop flushes the pending responses and stops the flushing routine.
+// Responses added afterwards are written immediately, one per datagram.
+func (c *combiner) stop() {
+	c.mu.Lock()
+	{
+		c.closed = true
+	}
+	c.mu.Unlock()
+
+	close(c.shutdown)
+	<-c.done
+}
+
+// add frames the response and queues it for its destination. If the frame
+// does not fit in the destination's pending datagram, that datagram is
+// written first. A frame larger than a combined datagram is rejected.
+func (c *combiner) add(r *Response) error {
+	if 2+r.Length > c.maxSize {
+		return ErrResponseTooLarge
+	}
+
+	atomic.AddInt64(&c.responses, 1)
+
+	frame := make([]byte, 2+r.Length)
+	binary.BigEndian.PutUint16(frame, uint16(r.Length))
+	copy(frame[2:], r.Data[:r.Length])
+
+	c.mu.Lock()
+
+	if c.closed {
+		c.mu.Unlock()
+		return c.write(&combined{udpAddr: r.UDPAddr, data: frame})
+	}
+
+	var full *combined
+
+	key := r.UDPAddr.String()
+	p, ok := c.pending[key]
+	switch {
+	case !ok:
+		c.pending[key] = &combined{udpAddr: r.UDPAddr, data: frame}
+	case len(p.data)+len(frame) > c.maxSize:
+		full = p
+		c.pending[key] = &combined{udpAddr: r.UDPAddr, data: frame}
+	default:
+		p.data = append(p.data, frame...)
+	}
+
+	c.mu.Unlock()
+
+	if full != nil {
+		return c.write(full)
+	}
+
+	return nil
+}
+
+// flush writes every pending datagram.
+func (c *combiner) flush() {
+	c.mu.Lock()
+	pending := c.pending
+	c.pending = make(map[string]*combined)
+	c.mu.Unlock()
+
+	for _, p := range pending {
+		if err := c.write(p); err != nil {
+			c.d.Event("combine", "ERROR : IPAddress[ %s ] %v", p.udpAddr, err)
+		}
+	}
+}
+
+// write sends a combined datagram through the listener's write path.
+func (c *combiner) write(p *combined) error {
+	atomic.AddInt64(&c.datagrams, 1)
+
+	r := Response{
+		UDPAddr: p.udpAddr,
+		Data:    p.data,
+		Length:  len(p.data),
+	}
+
+	return c.d.write(&r)
+}
+
+// stat returns the current counters.
+func (c *combiner) stat() CombineStat {
+	return CombineStat{
+		Responses: atomic.LoadInt64(&c.responses),
+		Datagrams: atomic.LoadInt64(&c.datagrams),
+	}
+}
@@ -14,6 +14,14 @@
 // The ConnHandler interface is implemented by the user to bind the listener
 // to a reader and writer for processing.
 //
+//     type PacketConnHandler interface {
+//         BindPacketConn(listener net.PacketConn) (io.Reader, io.Writer)
+//     }
+//
+// A connection provided through Config.Conn that is not a *net.UDPConn is
+// bound by BindPacketConn instead, so the ConnHandler must also implement
+// the PacketConnHandler interface to use one.
+//
 // ReqHandler
 //
 //     type ReqHandler interface {
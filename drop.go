@@ -0,0 +1,67 @@
+package udp
+
+import (
+	"net"
+	"sync/atomic"
+)
+
+// DropReason identifies why a datagram was dropped before processing.
+type DropReason int
+
+// Set of reasons a datagram can be dropped.
+const (
+	DropTooLarge DropReason = iota + 1
+	DropBandwidth
+	DropDryRun
+	DropQueueFull
+	DropRateLimit
+	DropPacketRate
+	DropNewSource
+)
+
+// String implements the fmt.Stringer interface.
+func (dr DropReason) String() string {
+	switch dr {
+	case DropTooLarge:
+		return "too large"
+	case DropBandwidth:
+		return "bandwidth"
+	case DropDryRun:
+		return "dry run"
+	case DropQueueFull:
+		return "queue full"
+	case DropRateLimit:
+		return "rate limit"
+	case DropPacketRate:
+		return "packet rate"
+	case DropNewSource:
+		return "new source"
+	}
+	return "unknown"
+}
+
+// DropInfo describes a datagram dropped before processing.
+type DropInfo struct {
+	UDPAddr *net.UDPAddr
+	Length  int
+	Reason  DropReason
+}
+
+// dropped counts the dropped datagram and reports it to the OnDrop
+// callback, unless the callback has already been called OnDropLimit times
+// in the last second.
+func (d *UDP) dropped(udpAddr *net.UDPAddr, length int, reason DropReason) {
+	atomic.AddInt64(&d.stats.drops, 1)
+
+	if d.OnDrop != nil && d.dropSampler.take(1) {
+		d.OnDrop(DropInfo{
+			UDPAddr: udpAddr,
+			Length:  length,
+			Reason:  reason,
+		})
+	}
+
+	if d.OnDone != nil {
+		d.OnDone()
+	}
+}
@@ -0,0 +1,28 @@
+//go:build !plan9
+
+package udp
+
+import (
+	"errors"
+	"syscall"
+)
+
+// bindKind classifies the error returned by the operating system.
+func bindKind(err error) error {
+	switch {
+	case errors.Is(err, syscall.EADDRINUSE):
+		return ErrBindAddrInUse
+	case errors.Is(err, syscall.EADDRNOTAVAIL):
+		return ErrBindAddrNotAvailable
+	case errors.Is(err, syscall.EACCES), errors.Is(err, syscall.EPERM):
+		return ErrBindPermission
+	}
+
+	return nil
+}
+
+// isRefused reports whether the error is a refused datagram reported by
+// the operating system.
+func isRefused(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
@@ -0,0 +1,23 @@
+package udp
+
+import (
+	"errors"
+	"syscall"
+)
+
+// bindKind classifies the error returned by the operating system. Plan 9
+// has no error numbers for an address in use or not available, so only
+// permission failures are classified.
+func bindKind(err error) error {
+	if errors.Is(err, syscall.EACCES) || errors.Is(err, syscall.EPERM) {
+		return ErrBindPermission
+	}
+
+	return nil
+}
+
+// isRefused reports whether the error is a refused datagram reported by
+// the operating system. Plan 9 does not report them.
+func isRefused(err error) bool {
+	return false
+}
@@ -0,0 +1,204 @@
+package udp
+
+import (
+	"strings"
+	"sync"
+)
+
+// GroupError collects the errors returned by the listeners of a group.
+type GroupError struct {
+	Errs []error
+}
+
+// Error implements the error interface.
+func (ge *GroupError) Error() string {
+	msgs := make([]string, len(ge.Errs))
+	for i, err := range ge.Errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the errors collected, so errors.Is and errors.As match
+// any of them.
+func (ge *GroupError) Unwrap() []error {
+	return ge.Errs
+}
+
+// Group coordinates the Start and Stop of a set of UDP values that depend
+// on each other. Listeners are started in the order they were added and
+// stopped in the reverse order, each one fully stopped before the next
+// one is. A listener can therefore rely on every listener added before
+// it running for as long as it is running itself.
+//
+// Setting Concurrent starts every listener at the same time instead,
+// giving up the start ordering for a faster boot. Stop ordering is kept.
+type Group struct {
+	Name       string
+	Config     Config
+	Concurrent bool
+
+	udps []*UDP
+	mu   sync.Mutex
+}
+
+// NewGroup creates a group whose listeners created by Listen share the
+// specified name and base configuration.
+func NewGroup(name string, cfg Config) *Group {
+	return &Group{
+		Name:   name,
+		Config: cfg,
+	}
+}
+
+// Listen creates a listener for the address from the group's base
+// configuration and adds it to the group. The override function, when
+// not nil, can change the configuration for this listener only.
+func (g *Group) Listen(addr string, override func(cfg *Config)) (*UDP, error) {
+	cfg := g.Config
+	cfg.Addr = addr
+
+	if override != nil {
+		override(&cfg)
+	}
+
+	u, err := New(g.Name, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	g.Add(u)
+
+	return u, nil
+}
+
+// Add appends a listener to the group. It will be started after and
+// stopped before every listener already in the group.
+func (g *Group) Add(u *UDP) {
+	g.mu.Lock()
+	{
+		g.udps = append(g.udps, u)
+	}
+	g.mu.Unlock()
+}
+
+// Listeners returns the listeners in the group in the order they were
+// added.
+func (g *Group) Listeners() []*UDP {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	udps := make([]*UDP, len(g.udps))
+	copy(udps, g.udps)
+
+	return udps
+}
+
+// GroupStats is a snapshot of the counters of every listener in a group.
+// Listeners holds the counters of each listener in the order they were
+// added, and Total their sum.
+type GroupStats struct {
+	Total     StatData
+	Listeners []StatData
+}
+
+// Stats returns a snapshot of the counters of every listener. It is safe
+// to call while the listeners are running.
+func (g *Group) Stats() GroupStats {
+	udps := g.Listeners()
+
+	gs := GroupStats{
+		Listeners: make([]StatData, len(udps)),
+	}
+
+	for i, u := range udps {
+		gs.Listeners[i] = u.Stats()
+		gs.Total = gs.Total.add(gs.Listeners[i])
+	}
+
+	return gs
+}
+
+// Start starts every listener in the order they were added. If a listener
+// fails to start, the ones already started are stopped in reverse order
+// and the error is returned. When the group is Concurrent, every failure
+// is collected into a GroupError.
+func (g *Group) Start() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.Concurrent {
+		return startConcurrent(g.udps)
+	}
+
+	for i, u := range g.udps {
+		if err := u.Start(); err != nil {
+			stopAll(g.udps[:i])
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Stop stops every listener in the reverse order they were added. All
+// listeners are stopped even if one fails, and every failure is collected
+// into a GroupError.
+func (g *Group) Stop() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return stopAll(g.udps)
+}
+
+// startConcurrent starts all of the listeners at the same time. If any
+// fail to start, the ones that started are stopped.
+func startConcurrent(udps []*UDP) error {
+	errs := make([]error, len(udps))
+
+	var wg sync.WaitGroup
+	wg.Add(len(udps))
+
+	for i, u := range udps {
+		go func(i int, u *UDP) {
+			errs[i] = u.Start()
+			wg.Done()
+		}(i, u)
+	}
+
+	wg.Wait()
+
+	var started []*UDP
+	var ge GroupError
+	for i, err := range errs {
+		if err != nil {
+			ge.Errs = append(ge.Errs, err)
+			continue
+		}
+		started = append(started, udps[i])
+	}
+
+	if ge.Errs == nil {
+		return nil
+	}
+
+	stopAll(started)
+
+	return &ge
+}
+
+// stopAll stops the listeners from last to first.
+func stopAll(udps []*UDP) error {
+	var ge GroupError
+	for i := len(udps) - 1; i >= 0; i-- {
+		if err := udps[i].Stop(); err != nil {
+			ge.Errs = append(ge.Errs, err)
+		}
+	}
+
+	if ge.Errs == nil {
+		return nil
+	}
+
+	return &ge
+}
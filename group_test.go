@@ -0,0 +1,242 @@
+package udp_test
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/udp"
+)
+
+// newTestUDP creates a UDP value listening on any loopback port.
+func newTestUDP(t *testing.T) *udp.UDP {
+	cfg := udp.Config{
+		NetType: "udp4",
+		Addr:    "127.0.0.1:0",
+
+		ConnHandler: udpConnHandler{},
+		ReqHandler:  udpReqHandler{},
+		RespHandler: udpRespHandler{},
+	}
+
+	u, err := udp.New("TEST", cfg)
+	if err != nil {
+		t.Fatal("\tShould be able to create a new UDP listener.", failed, err)
+	}
+
+	return u
+}
+
+// TestGroup validates a group starts and stops all of its listeners.
+func TestGroup(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	t.Log("Given the need to start and stop dependent listeners together.")
+	{
+		control, data := newTestUDP(t), newTestUDP(t)
+
+		var g udp.Group
+		g.Add(control)
+		g.Add(data)
+
+		if err := g.Start(); err != nil {
+			t.Fatal("\tShould be able to start the group.", failed, err)
+		}
+
+		if control.State() != udp.StateStarted || data.State() != udp.StateStarted {
+			t.Fatal("\tShould start every listener.", failed)
+		}
+		t.Log("\tShould start every listener.", success)
+
+		if err := g.Stop(); err != nil {
+			t.Fatal("\tShould be able to stop the group.", failed, err)
+		}
+
+		if control.State() != udp.StateStopped || data.State() != udp.StateStopped {
+			t.Fatal("\tShould stop every listener.", failed)
+		}
+		t.Log("\tShould stop every listener.", success)
+	}
+}
+
+// TestGroupStartFailure validates listeners already started are stopped
+// when a later one fails to start.
+func TestGroupStartFailure(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	t.Log("Given the need to roll back a group that fails to start.")
+	{
+		control, data := newTestUDP(t), newTestUDP(t)
+
+		// Starting the data listener ahead of time makes the group fail.
+		if err := data.Start(); err != nil {
+			t.Fatal("\tShould be able to start the UDP listener.", failed, err)
+		}
+		defer data.Stop()
+
+		var g udp.Group
+		g.Add(control)
+		g.Add(data)
+
+		if err := g.Start(); err == nil {
+			t.Fatal("\tShould fail to start the group.", failed)
+		}
+		t.Log("\tShould fail to start the group.", success)
+
+		if s := control.State(); s != udp.StateStopped {
+			t.Fatalf("\tShould stop the listener already started : %v %s", s, failed)
+		}
+		t.Log("\tShould stop the listener already started.", success)
+	}
+
+	t.Log("Given the need to classify the failures of a concurrent start.")
+	{
+		blocker, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			t.Fatal("\tShould be able to take an address.", failed, err)
+		}
+		defer blocker.Close()
+
+		cfg := udp.Config{
+			NetType: "udp4",
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler:  udpReqHandler{},
+			RespHandler: udpRespHandler{},
+		}
+
+		g := udp.NewGroup("TEST", cfg)
+		g.Concurrent = true
+
+		if _, err := g.Listen(blocker.LocalAddr().String(), nil); err != nil {
+			t.Fatal("\tShould be able to add a listener by address.", failed, err)
+		}
+
+		err = g.Start()
+
+		var be *udp.BindError
+		if !errors.Is(err, udp.ErrBindAddrInUse) || !errors.As(err, &be) {
+			t.Fatalf("\tShould match the bind failure in the group error : %v %s", err, failed)
+		}
+		t.Log("\tShould match the bind failure in the group error.", success)
+	}
+}
+
+// TestGroupListen validates listeners created from the group's base
+// configuration start concurrently and report every failure.
+func TestGroupListen(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	t.Log("Given the need to manage many listeners sharing a configuration.")
+	{
+		cfg := udp.Config{
+			NetType: "udp4",
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler:  udpReqHandler{},
+			RespHandler: udpRespHandler{},
+		}
+
+		g := udp.NewGroup("TEST", cfg)
+		g.Concurrent = true
+
+		first, err := g.Listen("127.0.0.1:0", nil)
+		if err != nil {
+			t.Fatal("\tShould be able to add a listener by address.", failed, err)
+		}
+
+		second, err := g.Listen("127.0.0.1:0", func(cfg *udp.Config) {
+			cfg.MaxIPv4Size = 512
+		})
+		if err != nil {
+			t.Fatal("\tShould be able to add a listener by address.", failed, err)
+		}
+		t.Log("\tShould be able to add a listener by address.", success)
+
+		if first.MaxIPv4Size != 0 || second.MaxIPv4Size != 512 {
+			t.Fatal("\tShould apply the override to one listener only.", failed)
+		}
+		t.Log("\tShould apply the override to one listener only.", success)
+
+		// Starting a listener ahead of time makes the group fail.
+		if err := second.Start(); err != nil {
+			t.Fatal("\tShould be able to start the UDP listener.", failed, err)
+		}
+		defer second.Stop()
+
+		err = g.Start()
+		ge, ok := err.(*udp.GroupError)
+		if !ok || len(ge.Errs) != 1 {
+			t.Fatalf("\tShould collect the start failure : %v %s", err, failed)
+		}
+		t.Log("\tShould collect the start failure.", success)
+
+		if s := first.State(); s != udp.StateStopped {
+			t.Fatalf("\tShould stop the listener that started : %v %s", s, failed)
+		}
+		t.Log("\tShould stop the listener that started.", success)
+
+		if n := len(g.Listeners()); n != 2 {
+			t.Fatalf("\tShould report both listeners : %d %s", n, failed)
+		}
+		t.Log("\tShould report both listeners.", success)
+	}
+}
+
+// TestGroupStats validates the counters of a group are reported for each
+// listener and summed.
+func TestGroupStats(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	t.Log("Given the need to monitor the listeners of a group together.")
+	{
+		first, second := newTestUDP(t), newTestUDP(t)
+
+		var g udp.Group
+		g.Add(first)
+		g.Add(second)
+
+		if err := g.Start(); err != nil {
+			t.Fatal("\tShould be able to start the group.", failed, err)
+		}
+		defer g.Stop()
+
+		send := func(u *udp.UDP, count int) {
+			conn, err := net.DialUDP("udp4", nil, u.Addr().(*net.UDPAddr))
+			if err != nil {
+				t.Fatal("\tShould be able to dial a new UDP connection.", failed, err)
+			}
+			defer conn.Close()
+
+			for i := 0; i < count; i++ {
+				conn.Write(make([]byte, 20))
+			}
+		}
+
+		send(first, 1)
+		send(second, 2)
+
+		for deadline := time.Now().Add(time.Second); g.Stats().Total.Process < 3; {
+			if time.Now().After(deadline) {
+				t.Fatalf("\tShould process every datagram : %+v %s", g.Stats(), failed)
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		gs := g.Stats()
+		if len(gs.Listeners) != 2 || gs.Listeners[0].Recv != 1 || gs.Listeners[1].Recv != 2 {
+			t.Fatalf("\tShould report the counters of each listener : %+v %s", gs.Listeners, failed)
+		}
+		t.Log("\tShould report the counters of each listener.", success)
+
+		if gs.Total.Recv != 3 || gs.Total.Process != 3 || gs.Total.RecvBytes != 60 {
+			t.Fatalf("\tShould sum the counters of the listeners : %+v %s", gs.Total, failed)
+		}
+		t.Log("\tShould sum the counters of the listeners.", success)
+	}
+}
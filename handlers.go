@@ -1,12 +1,16 @@
 package udp
 
 import (
+	"bytes"
+	"context"
 	"io"
 	"net"
 	"time"
 )
 
-// Request is the message received by the client.
+// Request is the message received by the client. IPv4-mapped IPv6 source
+// addresses are unmapped, so UDPAddr always holds a plain IPv4 address for
+// IPv4 clients, including those of a dual-stack listener.
 type Request struct {
 	UDP     *UDP
 	UDPAddr *net.UDPAddr
@@ -14,6 +18,103 @@ type Request struct {
 	ReadAt  time.Time
 	Data    []byte
 	Length  int
+
+	// Truncated is true when the datagram filled the capacity of the buffer
+	// returned by ReqHandler.Read, so it may have been larger than the
+	// buffer and cut short by the read. The capacity is used since Read
+	// commonly returns the buffer sliced to the length read.
+	Truncated bool
+
+	// WorkerState is the value returned by Config.NewWorkerState for
+	// the routine processing this request. It is nil when not configured.
+	WorkerState interface{}
+
+	// Session is the state kept for the client across its datagrams. It is
+	// nil unless Config.SessionTimeout is set.
+	Session *Session
+
+	// QueueDepthAtEnqueue is the number of requests that were waiting for
+	// a worker when this one was queued, a cheap signal of how busy the
+	// listener is. It is a point-in-time snapshot, not exact, since the
+	// workers keep taking requests while it is read. It is zero when the
+	// request is processed without workers.
+	QueueDepthAtEnqueue int
+
+	ctx  context.Context
+	rctx requestContext
+	buf  *[]byte
+}
+
+// requestKey is the context key of the request.
+type requestKey struct{}
+
+// requestContext carries the request in its context. It is kept in the
+// request so carrying it does not allocate.
+type requestContext struct {
+	context.Context
+	r *Request
+}
+
+// Value implements the context.Context interface.
+func (rc *requestContext) Value(key interface{}) interface{} {
+	if key == (requestKey{}) {
+		return rc.r
+	}
+	return rc.Context.Value(key)
+}
+
+// FromContext returns the request carried by the context of a request, or
+// by any context derived from it. Like the request itself, it must not be
+// used once Process has returned.
+func FromContext(ctx context.Context) (*Request, bool) {
+	r, ok := ctx.Value(requestKey{}).(*Request)
+	return r, ok
+}
+
+// Context returns the context of the request, which handlers should pass
+// to any work done on behalf of the request. It is canceled when the
+// request exceeds Config.RequestTimeout, or when Stop gives up waiting for
+// the request after Config.ShutdownTimeout. It carries the request, which
+// FromContext recovers from it or from any context derived from it, such
+// as one the handler adds a trace to before passing it on. Like the
+// request, it must not be used once Process has returned.
+func (r *Request) Context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+	return r.ctx
+}
+
+// Reply sends the data back to the client that sent the request.
+func (r *Request) Reply(data []byte) error {
+	resp := Response{
+		UDPAddr: r.UDPAddr,
+		Data:    data,
+		Length:  len(data),
+		Session: r.Session,
+	}
+
+	return r.UDP.Send(&resp)
+}
+
+// SendTo sends the data to any client.
+func (r *Request) SendTo(udpAddr *net.UDPAddr, data []byte) error {
+	resp := Response{
+		UDPAddr: udpAddr,
+		Data:    data,
+		Length:  len(data),
+	}
+
+	return r.UDP.Send(&resp)
+}
+
+// Sender is implemented by Request to let a handler send data to the client
+// or to other clients while processing. Every method goes through UDP.Send,
+// so the bandwidth limit and the write combiner apply, and the methods are
+// safe to call from multiple goroutines.
+type Sender interface {
+	Reply(data []byte) error
+	SendTo(udpAddr *net.UDPAddr, data []byte) error
 }
 
 // Response is message to send to the client.
@@ -21,6 +122,11 @@ type Response struct {
 	UDPAddr *net.UDPAddr
 	Data    []byte
 	Length  int
+
+	// Session is the session of the destination client. When sessions are
+	// enabled and it is left nil, it is looked up before the response is
+	// handed to RespHandler.
+	Session *Session
 }
 
 // ConnHandler is implemented by the user to bind the listener
@@ -31,6 +137,16 @@ type ConnHandler interface {
 	Bind(listener *net.UDPConn) (io.Reader, io.Writer)
 }
 
+// PacketConnHandler can be implemented by a ConnHandler to bind a
+// connection provided through Config.Conn. It is required when that
+// connection is not a *net.UDPConn.
+type PacketConnHandler interface {
+
+	// BindPacketConn is called in place of Bind to set the reader and
+	// writer.
+	BindPacketConn(listener net.PacketConn) (io.Reader, io.Writer)
+}
+
 // ReqHandler is implemented by the user to implement the processing
 // of request messages from the client.
 type ReqHandler interface {
@@ -45,6 +161,46 @@ type ReqHandler interface {
 	Process(r *Request)
 }
 
+// BufferReqHandler can be implemented by a ReqHandler to read datagrams
+// into buffers of Config.MaxPacketSize bytes pooled by the package instead
+// of allocating one per datagram. The buffer is handed to the request as
+// Request.Data, and both the buffer and the Request are returned to their
+// pools once processing ends. Neither may be kept past Process; copy what
+// is needed.
+type BufferReqHandler interface {
+
+	// ReadBuffer is called in place of Read with the buffer to read into.
+	// It returns the address of the client and the length of the datagram.
+	ReadBuffer(reader io.Reader, buf []byte) (*net.UDPAddr, int, error)
+}
+
+// WriterReqHandler can be implemented by a ReqHandler to write its response
+// straight into a buffer provided by the package instead of building one
+// and calling Send.
+type WriterReqHandler interface {
+
+	// ProcessWriter is called in place of Process. Whatever is written to
+	// the writer is sent back to the client once the method returns.
+	ProcessWriter(w *ResponseWriter, r *Request)
+}
+
+// ResponseWriter accumulates the response written by a WriterReqHandler.
+// The buffer is reused for other requests, so the writer and any slice
+// obtained from it are only valid during the call to ProcessWriter.
+type ResponseWriter struct {
+	buf bytes.Buffer
+}
+
+// Write appends the data to the response.
+func (w *ResponseWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+// Len returns the number of bytes written to the response.
+func (w *ResponseWriter) Len() int {
+	return w.buf.Len()
+}
+
 // RespHandler is implemented by the user to implement the processing
 // of the response messages to the client.
 type RespHandler interface {
@@ -0,0 +1,112 @@
+package udp
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// QueuePolicy defines what happens to a request when every worker is busy
+// and the queue is full.
+type QueuePolicy int
+
+// Set of queue overflow policies.
+const (
+	QueueDropNew    QueuePolicy = iota // Drop the request just read.
+	QueueDropOldest                    // Drop the request waiting the longest.
+	QueueBlock                         // Stop reading until a worker is free.
+)
+
+// String implements the fmt.Stringer interface.
+func (qp QueuePolicy) String() string {
+	switch qp {
+	case QueueDropNew:
+		return "drop new"
+	case QueueDropOldest:
+		return "drop oldest"
+	case QueueBlock:
+		return "block"
+	}
+	return "unknown"
+}
+
+// newWorkerState returns the state for a routine that processes requests.
+func (d *UDP) newWorkerState() interface{} {
+	if d.NewWorkerState == nil {
+		return nil
+	}
+	return d.NewWorkerState()
+}
+
+// startWorkers launches the routines processing the requests queued by the
+// accept routine and waits for all of them to be ready.
+func (d *UDP) startWorkers() {
+	work := make(chan *Request, d.QueueDepth)
+
+	// Stats reads the queue while the listener starts.
+	d.listenerMu.Lock()
+	{
+		d.work = work
+	}
+	d.listenerMu.Unlock()
+
+	var ready sync.WaitGroup
+	ready.Add(d.MaxWorkers)
+	d.wg.Add(d.MaxWorkers)
+
+	for i := 0; i < d.MaxWorkers; i++ {
+		go func() {
+			workerState := d.newWorkerState()
+			ready.Done()
+
+			// Process requests until the accept routine closes the queue.
+			for r := range work {
+				r.WorkerState = workerState
+				d.process(r)
+			}
+
+			d.wg.Done()
+		}()
+	}
+
+	ready.Wait()
+}
+
+// dispatch queues the request for the workers. What happens when every
+// worker is busy and the queue is full depends on the QueuePolicy.
+func (d *UDP) dispatch(r *Request) {
+	r.QueueDepthAtEnqueue = len(d.work)
+
+	if d.QueuePolicy == QueueBlock {
+		d.work <- r
+		return
+	}
+
+	for {
+		select {
+		case d.work <- r:
+			return
+		default:
+		}
+
+		if d.QueuePolicy == QueueDropNew {
+			d.queueDropped(r)
+			return
+		}
+
+		// Make room by dropping the request waiting the longest. A worker
+		// may have taken it first, in which case there is room already.
+		select {
+		case old := <-d.work:
+			d.queueDropped(old)
+		default:
+		}
+	}
+}
+
+// queueDropped counts and reports a request dropped from the queue.
+func (d *UDP) queueDropped(r *Request) {
+	total := atomic.AddInt64(&d.stats.queueDrops, 1)
+	d.Event("accept", "Queue Full Dropped : IPAddress[ %s ] Length[ %d ] Total[ %d ]", r.UDPAddr, r.Length, total)
+	d.dropped(r.UDPAddr, r.Length, DropQueueFull)
+	d.releaseRequest(r)
+}
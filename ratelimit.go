@@ -0,0 +1,137 @@
+package udp
+
+import (
+	"container/list"
+	"net"
+	"sync"
+	"time"
+)
+
+// RateLimitConfig configures the rate limit applied to each client IP.
+type RateLimitConfig struct {
+	PerIPRate     float64       `json:"per_ip_rate"`    // Datagrams per second allowed from each IP.
+	Burst         int           `json:"burst"`          // Datagrams allowed at once, one second of traffic by default.
+	SweepInterval time.Duration `json:"sweep_interval"` // How often idle clients are forgotten, a minute by default.
+	MaxSources    int           `json:"max_sources"`    // IPs tracked at once, the least recently seen forgotten first. Zero is unbounded.
+
+	// NewSourceRate caps the first datagrams per second accepted from IPs
+	// not tracked, across all of them, so a scan of source addresses is
+	// throttled without slowing down the clients already tracked. Up to
+	// NewSourceBurst new IPs, one second of them by default, are allowed at
+	// once. An IP that was forgotten counts as new again. Zero is unlimited.
+	NewSourceRate  float64 `json:"new_source_rate"`
+	NewSourceBurst int     `json:"new_source_burst"`
+}
+
+// resolved returns the configuration with the defaults applied.
+func (rlc RateLimitConfig) resolved() RateLimitConfig {
+	if rlc.Burst == 0 {
+		rlc.Burst = int(rlc.PerIPRate)
+		if rlc.Burst < 1 {
+			rlc.Burst = 1
+		}
+	}
+
+	if rlc.NewSourceRate > 0 && rlc.NewSourceBurst == 0 {
+		rlc.NewSourceBurst = int(rlc.NewSourceRate)
+		if rlc.NewSourceBurst < 1 {
+			rlc.NewSourceBurst = 1
+		}
+	}
+
+	if rlc.SweepInterval == 0 {
+		rlc.SweepInterval = time.Minute
+	}
+
+	return rlc
+}
+
+// limiter keeps a token bucket for every client IP.
+type limiter struct {
+	cfg RateLimitConfig
+
+	buckets    map[string]*list.Element
+	recent     *list.List // Sources from most to least recently seen.
+	newSources *bucket    // First datagrams of sources not tracked.
+	lastSweep  time.Time
+	mu         sync.Mutex
+}
+
+// source is the token bucket of a client IP.
+type source struct {
+	key    string
+	bucket *bucket
+}
+
+// newLimiter creates a limiter from the configuration.
+func newLimiter(cfg RateLimitConfig) *limiter {
+	l := limiter{
+		cfg:       cfg.resolved(),
+		buckets:   make(map[string]*list.Element),
+		recent:    list.New(),
+		lastSweep: time.Now(),
+	}
+
+	if l.cfg.NewSourceRate > 0 {
+		l.newSources = newBucket(l.cfg.NewSourceRate, float64(l.cfg.NewSourceBurst))
+	}
+
+	return &l
+}
+
+// check returns why a datagram from the IP is over the limit, or zero when
+// it is within its rate.
+func (l *limiter) check(ip net.IP) DropReason {
+	now := time.Now()
+	key := string(ip)
+
+	l.mu.Lock()
+
+	// Forget the clients whose bucket has refilled. A new bucket for them
+	// would be just as full, so no state is lost.
+	if now.Sub(l.lastSweep) >= l.cfg.SweepInterval {
+		for k, e := range l.buckets {
+			if e.Value.(*source).bucket.full(now) {
+				l.recent.Remove(e)
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	e, ok := l.buckets[key]
+	if ok {
+		l.recent.MoveToFront(e)
+	} else {
+
+		// Track the client only once its first datagram is allowed, so
+		// every datagram it sends until then counts as a first one.
+		if l.newSources != nil && !l.newSources.take(1) {
+			l.mu.Unlock()
+			return DropNewSource
+		}
+
+		// Make room by forgetting the client seen the longest ago.
+		if l.cfg.MaxSources > 0 && l.recent.Len() >= l.cfg.MaxSources {
+			oldest := l.recent.Back()
+			l.recent.Remove(oldest)
+			delete(l.buckets, oldest.Value.(*source).key)
+		}
+
+		e = l.recent.PushFront(&source{
+			key:    key,
+			bucket: newBucket(l.cfg.PerIPRate, float64(l.cfg.Burst)),
+		})
+		l.buckets[key] = e
+	}
+
+	b := e.Value.(*source).bucket
+
+	l.mu.Unlock()
+
+	if !b.take(1) {
+		return DropRateLimit
+	}
+
+	return 0
+}
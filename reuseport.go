@@ -0,0 +1,116 @@
+package udp
+
+import (
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// socket is a socket sharing the listener's address through SO_REUSEPORT.
+type socket struct {
+	conn   net.PacketConn
+	reader io.Reader
+}
+
+// bindReusers opens the sockets beyond the first one asked for by
+// ReusePort, on the address the listener is bound to so they share its
+// port when it was picked by the system. The caller must hold the
+// listener lock.
+func (d *UDP) bindReusers() error {
+	if d.ReusePort <= 1 {
+		return nil
+	}
+
+	udpAddr := d.listener.LocalAddr().(*net.UDPAddr)
+
+	for i := 1; i < d.ReusePort; i++ {
+		conn, err := d.listen(udpAddr)
+		if err != nil {
+			d.closeReusers()
+			return d.bindError(err)
+		}
+
+		if err := d.sizeBuffers(conn); err != nil {
+			conn.Close()
+			d.closeReusers()
+			return err
+		}
+
+		// Responses are written with the writer of the listener.
+		reader, _ := d.ConnHandler.Bind(conn)
+
+		d.reusers = append(d.reusers, socket{conn: conn, reader: reader})
+	}
+
+	return nil
+}
+
+// closeReusers closes the sockets sharing the listener's address. The
+// caller must hold the listener lock.
+func (d *UDP) closeReusers() {
+	for _, s := range d.reusers {
+		s.conn.Close()
+	}
+	d.reusers = nil
+}
+
+// acceptReusers launches a routine reading each socket sharing the
+// listener's address and waits for all of them to be ready.
+func (d *UDP) acceptReusers() {
+	d.listenerMu.RLock()
+	reusers := d.reusers
+	d.listenerMu.RUnlock()
+
+	var ready sync.WaitGroup
+	ready.Add(len(reusers))
+	d.accepting.Add(len(reusers))
+	d.wg.Add(len(reusers))
+
+	for _, s := range reusers {
+		go func(s socket) {
+			defer func() {
+				d.accepting.Done()
+				d.wg.Done()
+			}()
+
+			// Create the state this routine hands to every request it
+			// processes.
+			var workerState interface{}
+			if d.MaxWorkers == 0 {
+				workerState = d.newWorkerState()
+			}
+
+			ready.Done()
+
+			for {
+				udpAddr, data, length, buf, err := d.read(s.reader)
+				timeRead := time.Now()
+
+				if err != nil {
+					if atomic.LoadInt32(&d.shuttingDown) == 1 {
+						return
+					}
+
+					atomic.AddInt64(&d.stats.readErrors, 1)
+					d.Event("accept", "ERROR : %v", err)
+					d.reportError(err)
+
+					// Unlike the listener, these sockets are not
+					// re-established. The remaining ones take their share.
+					if !isTemporary(err) {
+						s.conn.Close()
+						return
+					}
+
+					continue
+				}
+
+				d.handle(udpAddr, data, length, buf, timeRead, workerState)
+			}
+		}(s)
+	}
+
+	ready.Wait()
+}
@@ -0,0 +1,158 @@
+package udp
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Session holds the state kept for a client across its datagrams. It is
+// created when the first datagram from the client's address is accepted
+// and closed once the client has been idle for Config.SessionTimeout, or
+// when the listener is stopped.
+type Session struct {
+	UDPAddr *net.UDPAddr
+	Created time.Time
+
+	lastSeen int64
+
+	value interface{}
+	mu    sync.Mutex
+}
+
+// LastSeen returns when the last datagram from the client was accepted.
+func (s *Session) LastSeen() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&s.lastSeen))
+}
+
+// Value returns the user data stored in the session.
+func (s *Session) Value() interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.value
+}
+
+// SetValue stores user data in the session. Requests from the same client
+// can be processed concurrently by the workers, so read-modify-write of
+// the value needs its own synchronization.
+func (s *Session) SetValue(v interface{}) {
+	s.mu.Lock()
+	{
+		s.value = v
+	}
+	s.mu.Unlock()
+}
+
+// sessions keeps the session of every client seen within the timeout.
+type sessions struct {
+	d       *UDP
+	timeout time.Duration
+
+	clients map[string]*Session
+	mu      sync.Mutex
+
+	shutdown chan struct{}
+	done     chan struct{}
+}
+
+// newSessions creates the session table for the listener.
+func newSessions(d *UDP) *sessions {
+	return &sessions{
+		d:        d,
+		timeout:  d.SessionTimeout,
+		clients:  make(map[string]*Session),
+		shutdown: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// minSweepInterval bounds how often the idle sessions are looked for.
+const minSweepInterval = time.Millisecond
+
+// start launches the routine closing the idle sessions.
+func (ss *sessions) start() {
+	interval := ss.timeout / 2
+	if interval < minSweepInterval {
+		interval = minSweepInterval
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case now := <-ticker.C:
+				ss.evict(now.Add(-ss.timeout))
+			case <-ss.shutdown:
+				close(ss.done)
+				return
+			}
+		}
+	}()
+}
+
+// stop stops the eviction routine and closes every session.
+func (ss *sessions) stop() {
+	close(ss.shutdown)
+	<-ss.done
+
+	ss.evict(time.Time{})
+}
+
+// touch returns the session of the client, creating it on the client's
+// first datagram.
+func (ss *sessions) touch(udpAddr *net.UDPAddr, now time.Time) *Session {
+	key := udpAddr.String()
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	s, ok := ss.clients[key]
+	if !ok {
+		s = &Session{
+			UDPAddr: udpAddr,
+			Created: now,
+		}
+		ss.clients[key] = s
+	}
+
+	// Record the datagram before evict can see the session again, so an
+	// idle session is either closed before the datagram or kept by it.
+	atomic.StoreInt64(&s.lastSeen, now.UnixNano())
+
+	return s
+}
+
+// lookup returns the session of the client, or nil when there is none.
+func (ss *sessions) lookup(udpAddr *net.UDPAddr) *Session {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	return ss.clients[udpAddr.String()]
+}
+
+// evict closes the sessions last seen before the time. The zero time
+// closes all of them.
+func (ss *sessions) evict(before time.Time) {
+	var closed []*Session
+
+	ss.mu.Lock()
+	for key, s := range ss.clients {
+		if before.IsZero() || s.LastSeen().Before(before) {
+			delete(ss.clients, key)
+			closed = append(closed, s)
+		}
+	}
+	ss.mu.Unlock()
+
+	for _, s := range closed {
+		ss.d.Event("session", "Closed : IPAddress[ %s ] LastSeen[ %v ]", s.UDPAddr, s.LastSeen())
+
+		if ss.d.OnSessionClose != nil {
+			ss.d.OnSessionClose(s)
+		}
+	}
+}
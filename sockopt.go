@@ -0,0 +1,71 @@
+package udp
+
+import "net"
+
+// SocketOptions are the options of the listener's socket as reported by
+// the kernel, which may differ from those asked for.
+type SocketOptions struct {
+	ReadBufferSize  int
+	WriteBufferSize int
+
+	// TTL is the hop limit of an IPv6 socket. TOS is reported for IPv4
+	// sockets only and TrafficClass for IPv6 sockets only.
+	TTL          int
+	TOS          int
+	TrafficClass int
+	DontFragment bool
+}
+
+// SocketOptions returns the options of the listener's socket. Linux
+// reports buffer sizes double those asked for, the extra being kept for
+// its own bookkeeping. It returns ErrNotStarted when the listener is not
+// running, and ErrSockoptUnsupported on other platforms or when the
+// connection provided through Config.Conn is not a *net.UDPConn.
+func (d *UDP) SocketOptions() (SocketOptions, error) {
+	d.listenerMu.RLock()
+	defer d.listenerMu.RUnlock()
+
+	if d.listener == nil {
+		return SocketOptions{}, ErrNotStarted
+	}
+
+	conn, ok := d.listener.(*net.UDPConn)
+	if !ok {
+		return SocketOptions{}, ErrSockoptUnsupported
+	}
+
+	return getSockopts(conn)
+}
+
+// setSockopts applies the configured IP options to the socket.
+func (d *UDP) setSockopts(conn *net.UDPConn) error {
+	so := SocketOptions{
+		TTL:          d.TTL,
+		TOS:          d.TOS,
+		TrafficClass: d.TrafficClass,
+		DontFragment: d.DontFragment,
+	}
+
+	if so == (SocketOptions{}) {
+		return nil
+	}
+
+	return setSockopts(conn, so)
+}
+
+// control runs the function with the socket's descriptor and returns its
+// error.
+func control(conn *net.UDPConn, f func(fd int) error) error {
+	rc, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	if cerr := rc.Control(func(fd uintptr) {
+		err = f(int(fd))
+	}); cerr != nil {
+		return cerr
+	}
+
+	return err
+}
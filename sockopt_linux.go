@@ -0,0 +1,152 @@
+//go:build linux
+
+package udp
+
+import (
+	"net"
+	"syscall"
+)
+
+// freeBindSupported reports whether IP_FREEBIND can be set on this platform.
+const freeBindSupported = true
+
+// freeBind is a net.ListenConfig Control function that sets IP_FREEBIND so
+// the socket can bind an address not configured on any local interface.
+func freeBind(network, address string, c syscall.RawConn) error {
+	var err error
+	if cerr := c.Control(func(fd uintptr) {
+		err = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_FREEBIND, 1)
+	}); cerr != nil {
+		return cerr
+	}
+
+	return err
+}
+
+// reusePortSupported reports whether SO_REUSEPORT can be set on this
+// platform.
+const reusePortSupported = true
+
+// reusePort is a net.ListenConfig Control function that sets SO_REUSEPORT
+// so several sockets can bind the same address and have the kernel spread
+// the datagrams across them.
+func reusePort(network, address string, c syscall.RawConn) error {
+	var err error
+	if cerr := c.Control(func(fd uintptr) {
+		err = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, soReusePort, 1)
+	}); cerr != nil {
+		return cerr
+	}
+
+	return err
+}
+
+// sockoptSupported reports whether the IP options of SocketOptions can be
+// set on this platform.
+const sockoptSupported = true
+
+// setSockopts sets the non zero IP options on the socket, using the IPv6
+// options for an IPv6 socket.
+func setSockopts(conn *net.UDPConn, so SocketOptions) error {
+	return control(conn, func(fd int) error {
+		ipv6, err := isIPv6(fd)
+		if err != nil {
+			return err
+		}
+
+		o := ipOpts(ipv6)
+
+		tos := so.TOS
+		if ipv6 {
+			tos = so.TrafficClass
+		}
+
+		if so.TTL > 0 {
+			if err := syscall.SetsockoptInt(fd, o.level, o.ttl, so.TTL); err != nil {
+				return err
+			}
+		}
+
+		if tos > 0 {
+			if err := syscall.SetsockoptInt(fd, o.level, o.tos, tos); err != nil {
+				return err
+			}
+		}
+
+		if so.DontFragment {
+			if err := syscall.SetsockoptInt(fd, o.level, o.mtuDiscover, o.pmtuDiscDo); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// getSockopts reads the options of the socket.
+func getSockopts(conn *net.UDPConn) (SocketOptions, error) {
+	var so SocketOptions
+
+	err := control(conn, func(fd int) error {
+		ipv6, err := isIPv6(fd)
+		if err != nil {
+			return err
+		}
+
+		o := ipOpts(ipv6)
+
+		tos := &so.TOS
+		if ipv6 {
+			tos = &so.TrafficClass
+		}
+
+		opts := []struct {
+			level, opt int
+			value      *int
+		}{
+			{syscall.SOL_SOCKET, syscall.SO_RCVBUF, &so.ReadBufferSize},
+			{syscall.SOL_SOCKET, syscall.SO_SNDBUF, &so.WriteBufferSize},
+			{o.level, o.ttl, &so.TTL},
+			{o.level, o.tos, tos},
+		}
+
+		for _, opt := range opts {
+			if *opt.value, err = syscall.GetsockoptInt(fd, opt.level, opt.opt); err != nil {
+				return err
+			}
+		}
+
+		discover, err := syscall.GetsockoptInt(fd, o.level, o.mtuDiscover)
+		if err != nil {
+			return err
+		}
+		so.DontFragment = discover == o.pmtuDiscDo
+
+		return nil
+	})
+
+	return so, err
+}
+
+// ipOptions names the options of one IP family.
+type ipOptions struct {
+	level       int
+	ttl         int
+	tos         int
+	mtuDiscover int
+	pmtuDiscDo  int
+}
+
+// ipOpts returns the options of the IPv6 or the IPv4 family.
+func ipOpts(ipv6 bool) ipOptions {
+	if ipv6 {
+		return ipOptions{syscall.IPPROTO_IPV6, syscall.IPV6_UNICAST_HOPS, syscall.IPV6_TCLASS, syscall.IPV6_MTU_DISCOVER, syscall.IPV6_PMTUDISC_DO}
+	}
+	return ipOptions{syscall.IPPROTO_IP, syscall.IP_TTL, syscall.IP_TOS, syscall.IP_MTU_DISCOVER, syscall.IP_PMTUDISC_DO}
+}
+
+// isIPv6 reports whether the socket belongs to the IPv6 family.
+func isIPv6(fd int) (bool, error) {
+	domain, err := syscall.GetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_DOMAIN)
+	return domain == syscall.AF_INET6, err
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package udp
+
+import "net"
+
+// broadcastSupported reports whether SO_BROADCAST can be set on this
+// platform.
+const broadcastSupported = false
+
+// setBroadcast is not supported on this platform.
+func setBroadcast(conn *net.UDPConn) error {
+	return ErrBroadcastUnsupported
+}
@@ -0,0 +1,39 @@
+//go:build !linux
+
+package udp
+
+import (
+	"net"
+	"syscall"
+)
+
+// freeBindSupported reports whether IP_FREEBIND can be set on this platform.
+const freeBindSupported = false
+
+// freeBind is not supported on this platform.
+func freeBind(network, address string, c syscall.RawConn) error {
+	return ErrFreeBindUnsupported
+}
+
+// reusePortSupported reports whether SO_REUSEPORT can be set on this
+// platform.
+const reusePortSupported = false
+
+// reusePort is not supported on this platform.
+func reusePort(network, address string, c syscall.RawConn) error {
+	return ErrReusePortUnsupported
+}
+
+// sockoptSupported reports whether the IP options of SocketOptions can be
+// set on this platform.
+const sockoptSupported = false
+
+// setSockopts is not supported on this platform.
+func setSockopts(conn *net.UDPConn, so SocketOptions) error {
+	return ErrSockoptUnsupported
+}
+
+// getSockopts is not supported on this platform.
+func getSockopts(conn *net.UDPConn) (SocketOptions, error) {
+	return SocketOptions{}, ErrSockoptUnsupported
+}
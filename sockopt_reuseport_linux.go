@@ -0,0 +1,6 @@
+//go:build linux && !mips && !mipsle && !mips64 && !mips64le && !sparc64
+
+package udp
+
+// soReusePort is SO_REUSEPORT, which the syscall package does not define.
+const soReusePort = 0xf
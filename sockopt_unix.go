@@ -0,0 +1,19 @@
+//go:build unix
+
+package udp
+
+import (
+	"net"
+	"syscall"
+)
+
+// broadcastSupported reports whether SO_BROADCAST can be set on this
+// platform.
+const broadcastSupported = true
+
+// setBroadcast sets SO_BROADCAST on the socket.
+func setBroadcast(conn *net.UDPConn) error {
+	return control(conn, func(fd int) error {
+		return syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_BROADCAST, 1)
+	})
+}
@@ -0,0 +1,184 @@
+package udp
+
+import (
+	"expvar"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// StatData is a snapshot of the counters of a listener. Every field is a
+// monotonically increasing counter except for InFlight, QueueLen and the
+// rates, which are bytes per second averaged over the last second.
+// Dividing ProcessTime by Process gives the mean handler latency.
+type StatData struct {
+	Recv           int64         // Datagrams read.
+	RecvBytes      int64         // Bytes of the datagrams read.
+	RecvRate       float64       // Bytes of the datagrams read per second.
+	ReadErrors     int64         // Reads that failed.
+	Drops          int64         // Datagrams dropped before processing.
+	QueueDrops     int64         // Requests dropped because the worker queue was full.
+	InboundDrops   int64         // Datagrams dropped by InboundBandwidthLimit.
+	DryRunDrops    int64         // Datagrams dropped because of DryRun.
+	NewSourceDrops int64         // First datagrams of new clients dropped by RateLimitConfig.NewSourceRate.
+	QueueLen       int64         // Requests waiting for a worker right now.
+	Process        int64         // Requests processed.
+	ProcessTime    time.Duration // Time spent processing requests.
+	Panics         int64         // Requests whose processing panicked.
+	InFlight       int64         // Requests being processed right now.
+	Writes         int64         // Datagrams written.
+	WriteBytes     int64         // Bytes of the datagrams written.
+	WriteRate      float64       // Bytes of the datagrams written per second.
+	WriteErrors    int64         // Writes that failed.
+}
+
+// stats holds the counters updated by the listener.
+type stats struct {
+	recv           int64
+	recvBytes      int64
+	readErrors     int64
+	drops          int64
+	queueDrops     int64
+	inboundDrops   int64
+	dryRunDrops    int64
+	newSourceDrops int64
+	process        int64
+	processTime    int64
+	panics         int64
+	inFlight       int64
+	writes         int64
+	writeBytes     int64
+	writeErrors    int64
+
+	recvRate  meter
+	writeRate meter
+}
+
+// meterSlots is the number of slots the window of a meter is divided in.
+const meterSlots = 10
+
+// meterSlot is the time covered by each slot of a meter.
+const meterSlot = time.Second / meterSlots
+
+// meter measures a rate over the last second. The second is divided in
+// slots so old amounts expire a slot at a time.
+type meter struct {
+	slots [meterSlots]int64
+	cur   int
+	start time.Time // When the current slot started.
+	mu    sync.Mutex
+}
+
+// advance moves to the slot of the time, clearing the slots skipped. The
+// caller must hold the lock.
+func (m *meter) advance(now time.Time) {
+	if m.start.IsZero() {
+		m.start = now
+		return
+	}
+
+	n := int(now.Sub(m.start) / meterSlot)
+	if n <= 0 {
+		return
+	}
+
+	if n >= meterSlots {
+		m.slots = [meterSlots]int64{}
+	} else {
+		for i := 0; i < n; i++ {
+			m.cur = (m.cur + 1) % meterSlots
+			m.slots[m.cur] = 0
+		}
+	}
+
+	m.start = m.start.Add(time.Duration(n) * meterSlot)
+}
+
+// add records the amount at the time.
+func (m *meter) add(amount int64, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.advance(now)
+	m.slots[m.cur] += amount
+}
+
+// rate returns the amount per second recorded over the second before the
+// time.
+func (m *meter) rate(now time.Time) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.advance(now)
+
+	var total int64
+	for _, amount := range m.slots {
+		total += amount
+	}
+
+	return float64(total)
+}
+
+// Stats returns a snapshot of the counters. It is safe to call while the
+// listener is running.
+func (d *UDP) Stats() StatData {
+	d.listenerMu.RLock()
+	queueLen := len(d.work)
+	d.listenerMu.RUnlock()
+
+	now := time.Now()
+
+	return StatData{
+		Recv:           atomic.LoadInt64(&d.stats.recv),
+		RecvBytes:      atomic.LoadInt64(&d.stats.recvBytes),
+		RecvRate:       d.stats.recvRate.rate(now),
+		ReadErrors:     atomic.LoadInt64(&d.stats.readErrors),
+		Drops:          atomic.LoadInt64(&d.stats.drops),
+		QueueDrops:     atomic.LoadInt64(&d.stats.queueDrops),
+		InboundDrops:   atomic.LoadInt64(&d.stats.inboundDrops),
+		DryRunDrops:    atomic.LoadInt64(&d.stats.dryRunDrops),
+		NewSourceDrops: atomic.LoadInt64(&d.stats.newSourceDrops),
+		QueueLen:       int64(queueLen),
+		Process:        atomic.LoadInt64(&d.stats.process),
+		ProcessTime:    time.Duration(atomic.LoadInt64(&d.stats.processTime)),
+		Panics:         atomic.LoadInt64(&d.stats.panics),
+		InFlight:       atomic.LoadInt64(&d.stats.inFlight),
+		Writes:         atomic.LoadInt64(&d.stats.writes),
+		WriteBytes:     atomic.LoadInt64(&d.stats.writeBytes),
+		WriteRate:      d.stats.writeRate.rate(now),
+		WriteErrors:    atomic.LoadInt64(&d.stats.writeErrors),
+	}
+}
+
+// add returns the sum of the counters.
+func (sd StatData) add(o StatData) StatData {
+	return StatData{
+		Recv:           sd.Recv + o.Recv,
+		RecvBytes:      sd.RecvBytes + o.RecvBytes,
+		RecvRate:       sd.RecvRate + o.RecvRate,
+		ReadErrors:     sd.ReadErrors + o.ReadErrors,
+		Drops:          sd.Drops + o.Drops,
+		QueueDrops:     sd.QueueDrops + o.QueueDrops,
+		InboundDrops:   sd.InboundDrops + o.InboundDrops,
+		DryRunDrops:    sd.DryRunDrops + o.DryRunDrops,
+		NewSourceDrops: sd.NewSourceDrops + o.NewSourceDrops,
+		QueueLen:       sd.QueueLen + o.QueueLen,
+		Process:        sd.Process + o.Process,
+		ProcessTime:    sd.ProcessTime + o.ProcessTime,
+		Panics:         sd.Panics + o.Panics,
+		InFlight:       sd.InFlight + o.InFlight,
+		Writes:         sd.Writes + o.Writes,
+		WriteBytes:     sd.WriteBytes + o.WriteBytes,
+		WriteRate:      sd.WriteRate + o.WriteRate,
+		WriteErrors:    sd.WriteErrors + o.WriteErrors,
+	}
+}
+
+// Publish exports the counters as an expvar variable with the name, so
+// they are served as JSON by the expvar handler at /debug/vars. Like
+// expvar.Publish, it panics if the name is already in use.
+func (d *UDP) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return d.Stats()
+	}))
+}
@@ -1,6 +1,7 @@
 package udp
 
 import (
+	"context"
 	"errors"
 	"io"
 	"net"
@@ -12,11 +13,41 @@ import (
 
 // Set of error variables for start up.
 var (
-	ErrInvalidConfiguration = errors.New("Invalid Configuration")
-	ErrInvalidNetType       = errors.New("Invalid NetType Configuration")
-	ErrInvalidConnHandler   = errors.New("Invalid Connection Handler Configuration")
-	ErrInvalidReqHandler    = errors.New("Invalid Request Handler Configuration")
-	ErrInvalidRespHandler   = errors.New("Invalid Response Handler Configuration")
+	ErrInvalidConfiguration   = errors.New("Invalid Configuration")
+	ErrInvalidNetType         = errors.New("Invalid NetType Configuration")
+	ErrInvalidConnHandler     = errors.New("Invalid Connection Handler Configuration")
+	ErrInvalidReqHandler      = errors.New("Invalid Request Handler Configuration")
+	ErrInvalidRespHandler     = errors.New("Invalid Response Handler Configuration")
+	ErrInvalidMaxSize         = errors.New("Invalid Max Datagram Size Configuration")
+	ErrFreeBindUnsupported    = errors.New("FreeBind Is Not Supported On This Platform")
+	ErrInvalidBandwidth       = errors.New("Invalid Bandwidth Limit Configuration")
+	ErrInvalidCombine         = errors.New("Invalid Write Combiner Configuration")
+	ErrInvalidDropLimit       = errors.New("Invalid Drop Callback Limit Configuration")
+	ErrInvalidShutdownTimeout = errors.New("Invalid Shutdown Timeout Configuration")
+	ErrInvalidBufferSize      = errors.New("Invalid Socket Buffer Size Configuration")
+	ErrInvalidWorkers         = errors.New("Invalid Worker Pool Configuration")
+	ErrInvalidRateLimit       = errors.New("Invalid Rate Limit Configuration")
+	ErrInvalidRequestTimeout  = errors.New("Invalid Request Timeout Configuration")
+	ErrInvalidMulticast       = errors.New("Invalid Multicast Group Configuration")
+	ErrBroadcastUnsupported   = errors.New("Broadcast Is Not Supported On This Platform")
+	ErrInvalidReusePort       = errors.New("Invalid Reuse Port Configuration")
+	ErrReusePortUnsupported   = errors.New("ReusePort Is Not Supported On This Platform")
+	ErrInvalidSessionTimeout  = errors.New("Invalid Session Timeout Configuration")
+	ErrInvalidSockopt         = errors.New("Invalid Socket Option Configuration")
+	ErrSockoptUnsupported     = errors.New("Socket Options Are Not Supported On This Platform")
+)
+
+// Set of error variables for shutdown.
+var (
+	ErrShutdownTimeout = errors.New("Timed Out Waiting For Requests To Finish")
+	ErrStillStopping   = errors.New("Requests Abandoned By Stop Are Still Running")
+)
+
+// Set of error variables for sending.
+var (
+	ErrBandwidthExceeded = errors.New("Outbound Bandwidth Limit Exceeded")
+	ErrNotStarted        = errors.New("Listener Is Not Started")
+	ErrResponseTooLarge  = errors.New("Response Too Large To Combine")
 )
 
 // temporary is declared to test for the existence of the method coming
@@ -34,14 +65,38 @@ type UDP struct {
 	port      int
 	udpAddr   *net.UDPAddr
 
-	listener   *net.UDPConn
+	listener   net.PacketConn
 	listenerMu sync.RWMutex
 
 	reader io.Reader
 	writer io.Writer
 
+	reusers   []socket
+	accepting sync.WaitGroup
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	inbound     *bucket
+	packets     *bucket
+	outbound    *bucket
+	dropSampler *bucket
+	combiner    *combiner
+	sessions    *sessions
+	work        chan *Request
+	buffers     *sync.Pool
+	requests    sync.Pool
+	limiter     *limiter
+
 	wg           sync.WaitGroup
+	exited       chan struct{} // Closed once the routines of the last run have exited.
 	shuttingDown int32
+	lastRecv     int64
+	stats        stats
+
+	state  int32
+	subs   []chan State
+	subsMu sync.Mutex
 }
 
 // New creates a new manager to service clients.
@@ -52,10 +107,23 @@ func New(name string, cfg Config) (*UDP, error) {
 		return nil, err
 	}
 
-	// Resolve the addr that is provided.
-	udpAddr, err := net.ResolveUDPAddr(cfg.NetType, cfg.Addr)
-	if err != nil {
-		return nil, err
+	// Wrap the handlers in the middleware.
+	cfg.chain()
+
+	// Resolve the addr that is provided, or take it from the connection
+	// that will be used in place of opening one.
+	var udpAddr *net.UDPAddr
+	if cfg.Conn != nil {
+		udpAddr, _ = cfg.Conn.LocalAddr().(*net.UDPAddr)
+		if udpAddr == nil {
+			udpAddr = &net.UDPAddr{}
+		}
+	} else {
+		var err error
+		udpAddr, err = net.ResolveUDPAddr(cfg.NetType, cfg.Addr)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// Create a UDP for this ipaddress and port.
@@ -68,71 +136,189 @@ func New(name string, cfg Config) (*UDP, error) {
 		udpAddr:   udpAddr,
 	}
 
+	if _, ok := cfg.ReqHandler.(BufferReqHandler); ok {
+		size := maxPacketSize(cfg.MaxPacketSize)
+		udp.buffers = &sync.Pool{
+			New: func() interface{} {
+				buf := make([]byte, size)
+				return &buf
+			},
+		}
+		udp.requests.New = func() interface{} {
+			return new(Request)
+		}
+	}
+
+	if cfg.OnDrop != nil {
+		udp.dropSampler = newBucket(float64(onDropLimit(cfg.OnDropLimit)), 0)
+	}
+
+	if cfg.RateLimit != nil {
+		udp.limiter = newLimiter(*cfg.RateLimit)
+	}
+
+	if cfg.InboundPacketLimit > 0 {
+		udp.packets = newBucket(float64(cfg.InboundPacketLimit), float64(cfg.InboundPacketBurst))
+	}
+
+	if cfg.InboundBandwidthLimit > 0 {
+		udp.inbound = newBucket(float64(cfg.InboundBandwidthLimit), float64(cfg.InboundBurst))
+	}
+
+	if cfg.OutboundBandwidthLimit > 0 {
+		udp.outbound = newBucket(float64(cfg.OutboundBandwidthLimit), float64(cfg.OutboundBurst))
+	}
+
 	return &udp, nil
 }
 
+// isTemporary reports whether the error is one the listener can recover
+// from without re-establishing the connection.
+func isTemporary(err error) bool {
+	if err == io.EOF {
+		return false
+	}
+
+	if e, ok := err.(temporary); ok {
+		return e.Temporary()
+	}
+
+	return true
+}
+
 // join takes an IP and port values and creates a cleaner string.
 func join(ip string, port int) string {
 	return net.JoinHostPort(ip, strconv.Itoa(port))
 }
 
-// Start begins to accept data.
+// Start begins to accept data. A failure to bind the address is returned
+// as a *BindError. After Stop gave up on the requests being processed,
+// Start returns ErrStillStopping until they have returned.
 func (d *UDP) Start() error {
 	d.listenerMu.Lock()
 	{
-		// If the listener has been started already, return an error.
-		if d.listener != nil {
+		// If the listener has been started already, or is still shutting
+		// down, return an error.
+		if s := d.State(); d.listener != nil || s == StateStarted || s == StateDraining {
 			d.listenerMu.Unlock()
 			return errors.New("this UDP has already been started")
 		}
+
+		// The routines of the last run would read the new socket next to
+		// the routines of this run once their requests return.
+		if d.exited != nil {
+			select {
+			case <-d.exited:
+			default:
+				d.listenerMu.Unlock()
+				return ErrStillStopping
+			}
+		}
+
+		// Start a listener for the specified addr and port.
+		if err := d.bind(); err != nil {
+			d.listenerMu.Unlock()
+			return err
+		}
+
+		// Open the sockets sharing the address with the listener.
+		if err := d.bindReusers(); err != nil {
+			d.listener.Close()
+			d.listener = nil
+			d.listenerMu.Unlock()
+			return err
+		}
+
+		// Create the context the requests of this run derive from.
+		d.ctx, d.cancel = context.WithCancel(context.Background())
+
+		// Nothing has been received by this run of the listener.
+		atomic.StoreInt64(&d.lastRecv, 0)
+		atomic.StoreInt32(&d.shuttingDown, 0)
+
+		// Start closing the sessions of idle clients.
+		if d.SessionTimeout > 0 {
+			d.sessions = newSessions(d)
+			d.sessions.start()
+		}
+
+		// Start flushing combined responses.
+		if d.CombineInterval > 0 {
+			d.combiner = newCombiner(d)
+			d.combiner.start(d.CombineInterval)
+		}
 	}
 	d.listenerMu.Unlock()
 
+	// Start the routines processing requests off the accept routine.
+	if d.MaxWorkers > 0 {
+		d.startWorkers()
+	}
+
+	// Start the routines reading the sockets sharing the address.
+	d.acceptReusers()
+
 	// We need to wait for the goroutine to initialize itself.
-	var waitStart sync.WaitGroup
-	waitStart.Add(1)
+	ready := make(chan struct{})
 
 	// Start the data accept routine.
 	d.wg.Add(1)
 	go func() {
-		for {
-			d.listenerMu.Lock()
-			{
-				// Start a listener for the specified addr and port is one
-				// does not exist.
-				if d.listener == nil {
-					var err error
-					d.listener, err = net.ListenUDP(d.NetType, d.udpAddr)
-					if err != nil {
-						panic(err)
-					}
 
-					// Ask the user to bind the reader and writer they want to
-					// use for this listener.
-					d.reader, d.writer = d.ConnHandler.Bind(d.listener)
+		// Create the state this routine hands to every request it processes.
+		var workerState interface{}
+		if d.MaxWorkers == 0 {
+			workerState = d.newWorkerState()
+		}
+
+		// Enter the started state before a read error can end the
+		// listener and stop it.
+		d.setState(StateStarted)
+		close(ready)
 
-					waitStart.Done()
+		// The error that ended the listener on its own.
+		var ended error
 
-					d.Event("accept", "Waiting For Data : IPAddress[ %s ]", join(d.ipAddress, d.port))
+		for {
+			var err error
+
+			d.listenerMu.Lock()
+			{
+				// Re-establish the listener if a read error closed it,
+				// but not once Stop has.
+				if d.listener == nil && atomic.LoadInt32(&d.shuttingDown) == 0 {
+					err = d.bind()
 				}
 			}
 			d.listenerMu.Unlock()
 
+			// A listener that can't be re-established ends.
+			if err != nil {
+				d.Event("accept", "ERROR : %v : Closing", err)
+				d.reportError(err)
+				ended = err
+				break
+			}
+
 			// Wait for a message to arrive.
-			udpAddr, data, length, err := d.ReqHandler.Read(d.reader)
+			udpAddr, data, length, buf, err := d.read(d.reader)
 			timeRead := time.Now()
 
 			if err != nil {
 				if atomic.LoadInt32(&d.shuttingDown) == 1 {
-					d.listenerMu.Lock()
-					{
-						d.listener = nil
-					}
-					d.listenerMu.Unlock()
 					break
 				}
 
+				atomic.AddInt64(&d.stats.readErrors, 1)
 				d.Event("accept", "ERROR : %v", err)
+				d.reportError(err)
+
+				// A connection provided by the user can't be re-established,
+				// so the end of it ends the listener.
+				if d.Conn != nil && !isTemporary(err) {
+					ended = err
+					break
+				}
 
 				if e, ok := err.(temporary); ok && !e.Temporary() {
 					d.listenerMu.Lock()
@@ -141,85 +327,513 @@ func (d *UDP) Start() error {
 						d.listener = nil
 					}
 					d.listenerMu.Unlock()
-
-					// Don't want to add a flag. So setting this back to
-					// 1 so when the listener is re-established, the call
-					// to Done does not fail.
-					waitStart.Add(1)
 				}
 
 				continue
 			}
 
-			// Check to see if this message is ipv6.
-			isIPv6 := true
-			if ip4 := udpAddr.IP.To4(); ip4 != nil {
-
-				// Make sure we return an IPv4 address if udpAddr
-				// is an IPv4-mapped IPv6 address.  Otherwise we
-				// could end up sending an IPv6 response.
-				udpAddr.IP = ip4
-				isIPv6 = false
-			}
+			d.handle(udpAddr, data, length, buf, timeRead, workerState)
+		}
 
-			// Create the request.
-			req := Request{
-				UDP:     d,
-				UDPAddr: udpAddr,
-				IsIPv6:  isIPv6,
-				ReadAt:  timeRead,
-				Data:    data,
-				Length:  length,
-			}
+		// When the listener ended on its own, shut it down as Stop would,
+		// unless a call to Stop is already doing so.
+		owner := ended != nil && atomic.CompareAndSwapInt32(&d.shuttingDown, 0, 1)
+		if owner {
+			d.halt()
+		}
 
-			// Process the request on this goroutine that is
-			// handling the socket connection.
-			d.ReqHandler.Process(&req)
+		// Let the workers finish the queued requests and exit once no
+		// routine is reading anymore.
+		d.accepting.Wait()
+		if d.work != nil {
+			close(d.work)
 		}
 
 		d.wg.Done()
 		d.Event("accept", "Shutdown : IPAddress[ %s ]", join(d.ipAddress, d.port))
 
-		return
+		if owner {
+			d.complete(ended)
+		}
 	}()
 
-	// Wait for the goroutine to initialize itself.
-	waitStart.Wait()
+	// Wait for the goroutine to be ready to read.
+	<-ready
+
+	if d.OnStart != nil {
+		d.OnStart(d.Addr())
+	}
 
 	return nil
 }
 
-// Stop shuts down the manager and closes all connections.
+// read reads the next datagram, into a pooled buffer when the request
+// handler supports it.
+func (d *UDP) read(reader io.Reader) (*net.UDPAddr, []byte, int, *[]byte, error) {
+	if d.buffers == nil {
+		udpAddr, data, length, err := d.ReqHandler.Read(reader)
+		return udpAddr, data, length, nil, err
+	}
+
+	buf := d.buffers.Get().(*[]byte)
+
+	udpAddr, length, err := d.ReqHandler.(BufferReqHandler).ReadBuffer(reader, *buf)
+	if err != nil {
+		d.buffers.Put(buf)
+		return nil, nil, 0, nil, err
+	}
+
+	return udpAddr, *buf, length, buf, nil
+}
+
+// release returns a pooled read buffer.
+func (d *UDP) release(buf *[]byte) {
+	if buf != nil {
+		d.buffers.Put(buf)
+	}
+}
+
+// releaseRequest returns the request and its read buffer to their pools
+// once processing has ended.
+func (d *UDP) releaseRequest(r *Request) {
+	if r.buf == nil {
+		return
+	}
+
+	d.release(r.buf)
+
+	*r = Request{}
+	d.requests.Put(r)
+}
+
+// handle checks the datagram read at the time against the configured
+// limits and processes it, or hands it to the workers.
+func (d *UDP) handle(udpAddr *net.UDPAddr, data []byte, length int, buf *[]byte, timeRead time.Time, workerState interface{}) {
+
+	// Record the arrival for liveness checks.
+	atomic.AddInt64(&d.stats.recv, 1)
+	atomic.AddInt64(&d.stats.recvBytes, int64(length))
+	d.stats.recvRate.add(int64(length), timeRead)
+	atomic.StoreInt64(&d.lastRecv, timeRead.UnixNano())
+
+	// Check to see if this message is ipv6.
+	isIPv6 := true
+	if ip4 := udpAddr.IP.To4(); ip4 != nil {
+
+		// Make sure we return an IPv4 address if udpAddr
+		// is an IPv4-mapped IPv6 address.  Otherwise we
+		// could end up sending an IPv6 response.
+		udpAddr.IP = ip4
+		isIPv6 = false
+	}
+
+	if !d.admit(udpAddr, length, isIPv6) {
+		d.release(buf)
+		return
+	}
+
+	// Find the state kept for the client.
+	var session *Session
+	if d.sessions != nil {
+		session = d.sessions.touch(udpAddr, timeRead)
+	}
+
+	// Create the request, reusing one when the buffers are pooled.
+	var req *Request
+	if buf != nil {
+		req = d.requests.Get().(*Request)
+	} else {
+		req = new(Request)
+	}
+
+	*req = Request{
+		UDP:     d,
+		UDPAddr: udpAddr,
+		IsIPv6:  isIPv6,
+		ReadAt:  timeRead,
+		Data:    data,
+		Length:  length,
+
+		// A datagram filling the whole buffer may have been cut short.
+		Truncated: cap(data) > 0 && length >= cap(data),
+
+		WorkerState: workerState,
+		Session:     session,
+
+		buf: buf,
+	}
+
+	// Hand the request to the workers when there are any.
+	if d.work != nil {
+		d.dispatch(req)
+		return
+	}
+
+	// Process the request on this goroutine that is
+	// handling the socket connection.
+	d.process(req)
+}
+
+// admit checks the datagram against the configured limits, dropping it
+// when it is over one of them.
+func (d *UDP) admit(udpAddr *net.UDPAddr, length int, isIPv6 bool) bool {
+
+	// Reject datagrams larger than the address family allows.
+	if max := d.maxSize(isIPv6); length > max {
+		d.Event("accept", "ERROR : Datagram Too Large : IPAddress[ %s ] Length[ %d ] Max[ %d ]", udpAddr, length, max)
+		d.dropped(udpAddr, length, DropTooLarge)
+		return false
+	}
+
+	// Drop datagrams from clients sending faster than their rate, and the
+	// first ones from new clients appearing faster than theirs.
+	if d.limiter != nil {
+		switch d.limiter.check(udpAddr.IP) {
+		case DropRateLimit:
+			d.Event("accept", "Rate Limit Dropped : IPAddress[ %s ] Length[ %d ]", udpAddr, length)
+			d.dropped(udpAddr, length, DropRateLimit)
+			return false
+		case DropNewSource:
+			total := atomic.AddInt64(&d.stats.newSourceDrops, 1)
+			d.Event("accept", "New Source Rate Dropped : IPAddress[ %s ] Length[ %d ] Total[ %d ]", udpAddr, length, total)
+			d.dropped(udpAddr, length, DropNewSource)
+			return false
+		}
+	}
+
+	// Shed datagrams while the inbound packet rate is over the cap.
+	if d.packets != nil && !d.packets.take(1) {
+		d.Event("accept", "Packet Rate Dropped : IPAddress[ %s ] Length[ %d ]", udpAddr, length)
+		d.dropped(udpAddr, length, DropPacketRate)
+		return false
+	}
+
+	// Shed datagrams while the inbound bandwidth is over the cap.
+	if d.inbound != nil && !d.inbound.take(float64(length)) {
+		total := atomic.AddInt64(&d.stats.inboundDrops, 1)
+		d.Event("accept", "Bandwidth Dropped : IPAddress[ %s ] Length[ %d ] Total[ %d ]", udpAddr, length, total)
+		d.dropped(udpAddr, length, DropBandwidth)
+		return false
+	}
+
+	// In dry run mode the datagram is dropped without processing.
+	if d.DryRun {
+		total := atomic.AddInt64(&d.stats.dryRunDrops, 1)
+		d.Event("accept", "Dry Run Dropped : IPAddress[ %s ] Length[ %d ] Total[ %d ]", udpAddr, length, total)
+		d.dropped(udpAddr, length, DropDryRun)
+		return false
+	}
+
+	return true
+}
+
+// process calls the request handler, recovering from a panic unless the
+// panic handler asks for it to propagate.
+func (d *UDP) process(r *Request) {
+
+	// Bound the request to its time budget, counted from when it was read.
+	r.rctx = requestContext{Context: d.ctx, r: r}
+	r.ctx = &r.rctx
+	cancel := func() {}
+	if d.RequestTimeout > 0 {
+		r.ctx, cancel = context.WithDeadline(r.ctx, r.ReadAt.Add(d.RequestTimeout))
+	}
+
+	start := time.Now()
+	atomic.AddInt64(&d.stats.inFlight, 1)
+	defer func() {
+		atomic.AddInt64(&d.stats.inFlight, -1)
+		atomic.AddInt64(&d.stats.process, 1)
+		atomic.AddInt64(&d.stats.processTime, int64(time.Since(start)))
+
+		// The context is parented to the request, so it is canceled before
+		// the request is released and reused.
+		cancel()
+		d.releaseRequest(r)
+
+		if d.OnDone != nil {
+			d.OnDone()
+		}
+	}()
+
+	defer func() {
+		if v := recover(); v != nil {
+			atomic.AddInt64(&d.stats.panics, 1)
+			d.Event("accept", "PANIC : IPAddress[ %s ] %v", r.UDPAddr, v)
+
+			if d.PanicHandler != nil && d.PanicHandler(r, v) {
+				panic(v)
+			}
+		}
+	}()
+
+	wh, ok := d.ReqHandler.(WriterReqHandler)
+	if !ok {
+		d.ReqHandler.Process(r)
+		return
+	}
+
+	w := responsePool.Get().(*ResponseWriter)
+	defer func() {
+		w.buf.Reset()
+		responsePool.Put(w)
+	}()
+
+	wh.ProcessWriter(w, r)
+
+	if w.Len() == 0 {
+		return
+	}
+
+	resp := Response{
+		UDPAddr: r.UDPAddr,
+		Data:    w.buf.Bytes(),
+		Length:  w.Len(),
+	}
+
+	if err := d.Send(&resp); err != nil {
+		d.Event("accept", "ERROR : IPAddress[ %s ] %v", r.UDPAddr, err)
+	}
+}
+
+// responsePool provides the buffers handed to a WriterReqHandler.
+var responsePool = sync.Pool{
+	New: func() interface{} {
+		return new(ResponseWriter)
+	},
+}
+
+// Stop shuts down the manager and closes all connections. Datagrams not
+// yet read are dropped, but Stop waits for the request being processed to
+// finish, for up to ShutdownTimeout when configured.
 func (d *UDP) Stop() error {
+
+	// If the listener has been stopped already, or is being stopped,
+	// return an error. Otherwise mark that we are shutting down.
+	if d.State() != StateStarted || !atomic.CompareAndSwapInt32(&d.shuttingDown, 0, 1) {
+		return errors.New("this UDP has already been stopped")
+	}
+
+	// Don't accept anymore client data.
+	d.halt()
+
+	return d.complete(nil)
+}
+
+// halt writes any combined responses while the listener is still open,
+// then closes the sockets.
+func (d *UDP) halt() {
+	if d.combiner != nil {
+		d.combiner.stop()
+	}
+
+	d.close()
+}
+
+// complete waits for the requests being processed to finish, for up to
+// ShutdownTimeout when configured, and finishes the shutdown with the
+// cause unless waiting timed out.
+func (d *UDP) complete(cause error) error {
+	ctx := context.Background()
+	if d.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.ShutdownTimeout)
+		defer cancel()
+	}
+
+	err := d.wait(ctx)
+	if err == nil {
+		err = cause
+	}
+
+	return d.finish(err)
+}
+
+// StopWithContext drains the listener before shutting it down. It stops
+// reading new datagrams but keeps the socket open while the requests
+// already read, including those queued for the workers, finish and send
+// their responses. Combined responses are then written and the socket is
+// closed. If the context is done before the requests finish, they are
+// canceled, the socket is closed and ErrShutdownTimeout is returned. The
+// listener is in StateDraining until it is stopped.
+//
+// ShutdownTimeout does not apply; the context bounds the drain. A socket
+// provided through Config.Conn that ignores read deadlines keeps being
+// read until the context is done.
+func (d *UDP) StopWithContext(ctx context.Context) error {
+	// If the listener has been stopped already, or is being stopped,
+	// return an error. Otherwise mark that we are shutting down.
+	if d.State() != StateStarted || !atomic.CompareAndSwapInt32(&d.shuttingDown, 0, 1) {
+		return errors.New("this UDP has already been stopped")
+	}
+
+	d.setState(StateDraining)
+
 	d.listenerMu.Lock()
 	{
-		// If the listener has been stopped already, return an error.
-		if d.listener == nil {
-			d.listenerMu.Unlock()
-			return errors.New("this UDP has already been stopped")
+		// Wake the reading routines without closing the sockets.
+		past := time.Unix(1, 0)
+		if d.listener != nil {
+			d.listener.SetReadDeadline(past)
+		}
+		for _, s := range d.reusers {
+			s.conn.SetReadDeadline(past)
 		}
 	}
 	d.listenerMu.Unlock()
 
-	// Mark that we are shutting down.
-	atomic.StoreInt32(&d.shuttingDown, 1)
+	err := d.wait(ctx)
 
-	// Don't accept anymore client data.
+	// Write any combined responses, then close the sockets.
+	d.halt()
+
+	return d.finish(err)
+}
+
+// close closes the sockets of the listener.
+func (d *UDP) close() {
+	d.listenerMu.Lock()
+	{
+		// The listener is nil while a read error has it re-established.
+		if d.listener != nil {
+			d.listener.Close()
+			d.listener = nil
+		}
+		d.closeReusers()
+	}
+	d.listenerMu.Unlock()
+}
+
+// wait waits for the reading routines and the workers to terminate, which
+// includes finishing the requests being processed. If the context is done
+// first, the requests are told to give up and ErrShutdownTimeout is
+// returned.
+func (d *UDP) wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	// Start waits for the routines to exit before starting a new run.
 	d.listenerMu.Lock()
 	{
-		d.listener.Close()
+		d.exited = done
 	}
 	d.listenerMu.Unlock()
 
-	// Wait for the accept routine to terminate.
-	d.wg.Wait()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
 
-	return nil
+		// Tell the requests being abandoned to give up.
+		d.cancel()
+		return ErrShutdownTimeout
+	}
+}
+
+// finish completes the shutdown once the requests are done or abandoned.
+func (d *UDP) finish(err error) error {
+	d.cancel()
+
+	// Close the sessions once no request can use them.
+	if d.sessions != nil {
+		d.sessions.stop()
+	}
+
+	d.setState(StateStopped)
+	d.stopped(err)
+
+	return err
 }
 
-// Send will deliver the response back to the client.
+// stopped reports the result of Stop to the OnStop callback.
+func (d *UDP) stopped(err error) {
+	if d.OnStop != nil {
+		d.OnStop(err)
+	}
+}
+
+// Send will deliver the response to the client. It does not require a
+// request, so it can be called from any goroutine to push data to a known
+// address, but returns ErrNotStarted when the listener is not running.
+// When the write combiner is enabled the response is queued and written
+// with the next combined datagram for its destination.
 func (d *UDP) Send(r *Response) error {
-	return d.RespHandler.Write(r, d.writer)
+	d.listenerMu.RLock()
+	started := d.listener != nil
+	d.listenerMu.RUnlock()
+
+	if !started {
+		return ErrNotStarted
+	}
+
+	if d.combiner != nil {
+		return d.combiner.add(r)
+	}
+
+	return d.write(r)
+}
+
+// CombineStats returns the counters of the write combiner.
+func (d *UDP) CombineStats() CombineStat {
+	if d.combiner == nil {
+		return CombineStat{}
+	}
+
+	return d.combiner.stat()
+}
+
+// write delivers a datagram through the response handler.
+func (d *UDP) write(r *Response) error {
+
+	// Keep the outbound bandwidth under the configured cap.
+	if d.outbound != nil {
+		if d.OutboundDrop {
+			if !d.outbound.take(float64(r.Length)) {
+				atomic.AddInt64(&d.stats.writeErrors, 1)
+				return ErrBandwidthExceeded
+			}
+		} else if wait := d.outbound.reserve(float64(r.Length)); wait > 0 {
+			time.Sleep(wait)
+		}
+	}
+
+	if r.Session == nil && d.sessions != nil {
+		r.Session = d.sessions.lookup(r.UDPAddr)
+	}
+
+	if err := d.RespHandler.Write(r, d.writer); err != nil {
+		atomic.AddInt64(&d.stats.writeErrors, 1)
+		d.reportError(err)
+		return err
+	}
+
+	atomic.AddInt64(&d.stats.writes, 1)
+	atomic.AddInt64(&d.stats.writeBytes, int64(r.Length))
+	d.stats.writeRate.add(int64(r.Length), time.Now())
+	return nil
+}
+
+// LastRecvAt returns the time the last datagram was received since Start.
+// It returns the zero time when no datagram has been received.
+func (d *UDP) LastRecvAt() time.Time {
+	nano := atomic.LoadInt64(&d.lastRecv)
+	if nano == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nano)
+}
+
+// SinceLastRecv returns how long it has been since the last datagram was
+// received. It returns zero when no datagram has been received.
+func (d *UDP) SinceLastRecv() time.Duration {
+	last := d.LastRecvAt()
+	if last.IsZero() {
+		return 0
+	}
+	return time.Since(last)
 }
 
 // Addr returns the local listening network address.
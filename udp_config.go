@@ -1,5 +1,17 @@
 package udp
 
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// Largest UDP payloads that fit in a single IPv4 or IPv6 packet.
+const (
+	MaxIPv4DatagramSize = 65507
+	MaxIPv6DatagramSize = 65527
+)
+
 // OptEvent defines an handler used to provide events.
 type OptEvent struct {
 	Event func(event string, format string, a ...interface{})
@@ -19,6 +31,191 @@ type Config struct {
 	// *************************************************************************
 
 	OptEvent
+
+	// ReqMiddleware and RespMiddleware wrap ReqHandler and RespHandler when
+	// New is called. The first middleware is the outermost, so it sees a
+	// request first and a response first. A middleware usually embeds the
+	// handler it is given and overrides just the methods it needs; one that
+	// hides the ProcessWriter or ReadBuffer method of the handler turns it
+	// back into a plain ReqHandler.
+	ReqMiddleware  []func(h ReqHandler) ReqHandler
+	RespMiddleware []func(h RespHandler) RespHandler
+
+	// Conn, when not nil, is used as the listener's connection in place of
+	// opening a socket, and NetType and Addr are ignored. This allows for
+	// custom transports and fake connections in tests. Unless it is a
+	// *net.UDPConn, ConnHandler must implement PacketConnHandler to bind
+	// it. Stop closes it, and since it can't be re-established, so does a
+	// read error that is not temporary, which stops the listener the way
+	// Stop would.
+	Conn net.PacketConn
+
+	// MaxWorkers processes requests on a pool of that many routines instead
+	// of on the routine reading the socket. Up to QueueDepth requests wait
+	// for a free worker; beyond that QueuePolicy decides between dropping
+	// the new request, the oldest queued one, or blocking the reads until a
+	// worker is free. Zero processes every request on the reading routine.
+	MaxWorkers  int
+	QueueDepth  int
+	QueuePolicy QueuePolicy
+
+	// NewWorkerState is called once by each routine that calls Process. The
+	// value returned is handed to every request processed by that routine
+	// through Request.WorkerState, so handlers can reuse scratch buffers.
+	// The value is owned by a single routine and must not be shared.
+	NewWorkerState func() interface{}
+
+	// MaxIPv4Size and MaxIPv6Size reject datagrams larger than the value
+	// from clients of that address family before they are processed. Zero
+	// uses the largest payload the family can carry.
+	MaxIPv4Size int
+	MaxIPv6Size int
+
+	// DryRun binds the address but drops every datagram without processing
+	// it, proving the configuration can serve traffic. The address is held
+	// until Stop is called.
+	DryRun bool
+
+	// FreeBind sets IP_FREEBIND on the socket so Addr can name an address
+	// that is not yet configured on any local interface. Linux only.
+	FreeBind bool
+
+	// MulticastGroup joins the multicast group address on the port of Addr,
+	// whose host is then ignored, so the datagrams sent to the group are
+	// received. MulticastInterface names the interface to join on, the
+	// system default when empty. The membership is left when Stop closes
+	// the socket.
+	MulticastGroup     string
+	MulticastInterface string
+
+	// ReusePort opens that many sockets on Addr with SO_REUSEPORT, each
+	// with its own routine reading it, and the kernel spreads the datagrams
+	// across them so reads scale past one core. ConnHandler.Bind is called
+	// for every socket and ReqHandler.Read is then called concurrently, but
+	// responses are all written with the writer of the first socket. Linux
+	// only, and not together with Conn or MulticastGroup.
+	ReusePort int
+
+	// Broadcast sets SO_BROADCAST on the socket so datagrams can be sent to
+	// and received from a broadcast address.
+	Broadcast bool
+
+	// OutboundBandwidthLimit caps the bytes per second written by Send.
+	// After an idle period up to OutboundBurst bytes, one second of traffic
+	// by default, can be sent at once. Beyond that, sends are delayed until
+	// the average is back under the cap, or when OutboundDrop is set are
+	// dropped with ErrBandwidthExceeded. StatData.WriteRate reports the
+	// bytes sent over the last second.
+	OutboundBandwidthLimit int
+	OutboundBurst          int
+	OutboundDrop           bool
+
+	// RateLimit, when not nil, drops the datagrams of a client IP sending
+	// faster than its rate before they are processed. Each IP has its own
+	// token bucket, and IPs that have gone quiet are forgotten.
+	RateLimit *RateLimitConfig
+
+	// InboundBandwidthLimit caps the bytes per second accepted for
+	// processing. After an idle period up to InboundBurst bytes, one second
+	// of traffic by default, are accepted at once. Datagrams beyond that
+	// are dropped until the average is back under the cap. It is checked
+	// after InboundPacketLimit, so a datagram dropped by the packet rate
+	// does not count against the bandwidth, while one dropped by the
+	// bandwidth has already counted against the packet rate.
+	// StatData.RecvRate reports the bytes read over the last second,
+	// including the datagrams dropped.
+	InboundBandwidthLimit int
+	InboundBurst          int
+
+	// InboundPacketLimit caps the datagrams per second accepted for
+	// processing from all clients together. After an idle period up to
+	// InboundPacketBurst datagrams, one second of traffic by default, are
+	// accepted at once. Datagrams beyond that are dropped until the average
+	// is back under the cap.
+	InboundPacketLimit int
+	InboundPacketBurst int
+
+	// PanicHandler is called when ReqHandler.Process panics. Returning true
+	// propagates the panic and crashes the process, returning false
+	// recovers and the listener continues with the next datagram. When
+	// nil, every panic is recovered.
+	PanicHandler func(r *Request, v interface{}) bool
+
+	// CombineInterval enables the write combiner. Responses handed to Send
+	// are held per destination and written every interval as a single
+	// datagram of at most CombineMaxSize bytes, MaxIPv4DatagramSize by
+	// default. Each response in the datagram is framed as a 2 byte big
+	// endian length followed by that many bytes of data, so the receiver
+	// must split the datagram back into responses. Send returns
+	// ErrResponseTooLarge for a response too large to fit in a combined
+	// datagram with its frame.
+	CombineInterval time.Duration
+	CombineMaxSize  int
+
+	// OnDrop is called with the details of a datagram dropped before it
+	// was processed. To keep a flood from turning into a flood of calls,
+	// it is called at most OnDropLimit times per second, 100 by default,
+	// and the drops beyond that are not reported.
+	OnDrop      func(di DropInfo)
+	OnDropLimit int
+
+	// OnDone is called once each datagram read has been processed or
+	// dropped, after it is counted in Stats, so the sender of a datagram
+	// can wait for it to be handled without polling.
+	OnDone func()
+
+	// OnStart is called once the listener is reading, with the address it
+	// is bound to, and OnStop when Stop returns, with the error it returns,
+	// or once a listener that ended on its own has stopped, with the error
+	// that ended it.
+	// OnError is called with every failed read, every failed attempt to
+	// re-establish the listener after one, and every failed write of a
+	// response, the same failures reported through OptEvent.
+	OnStart func(addr net.Addr)
+	OnStop  func(err error)
+	OnError func(err error)
+
+	// ShutdownTimeout bounds how long Stop waits for the request being
+	// processed to finish. Stop returns ErrShutdownTimeout when it gives up
+	// waiting, and the listener can't be started again until the requests
+	// it gave up on have returned. Zero waits for as long as processing
+	// takes.
+	ShutdownTimeout time.Duration
+
+	// RequestTimeout cancels the context of a request that is still being
+	// processed this long after it was read. Zero never times out.
+	RequestTimeout time.Duration
+
+	// SessionTimeout enables sessions. Each client address gets a Session,
+	// handed to every request and response for it, that is closed once the
+	// client has been idle for the timeout. Idle sessions are looked for
+	// every half timeout, but no more than once a millisecond, so a session
+	// can outlive the timeout by that much. OnSessionClose is called with
+	// every session closed, including those closed by Stop.
+	SessionTimeout time.Duration
+	OnSessionClose func(s *Session)
+
+	// MaxPacketSize is the size of the buffers pooled for a ReqHandler that
+	// implements BufferReqHandler, MaxIPv6DatagramSize by default. Larger
+	// datagrams are truncated.
+	MaxPacketSize int
+
+	// ReadBufferSize and WriteBufferSize set the size of the kernel receive
+	// and send buffers of the socket. Zero keeps the system default.
+	ReadBufferSize  int
+	WriteBufferSize int
+
+	// TTL sets the time to live, or the hop limit on an IPv6 socket, of the
+	// datagrams sent. TOS sets the type of service byte, which carries the
+	// DSCP marking, on an IPv4 socket and TrafficClass the same byte on an
+	// IPv6 socket. DontFragment sets the don't fragment bit so a datagram
+	// too large for the path fails to send instead of being fragmented.
+	// Zero keeps the system default. Linux only; use SocketOptions to see
+	// what the kernel applied.
+	TTL          int
+	TOS          int
+	TrafficClass int
+	DontFragment bool
 }
 
 // Validate checks the configuration to required items.
@@ -27,7 +224,7 @@ func (cfg *Config) Validate() error {
 		return ErrInvalidConfiguration
 	}
 
-	if cfg.NetType != "udp" && cfg.NetType != "udp4" && cfg.NetType != "udp6" {
+	if cfg.Conn == nil && cfg.NetType != "udp" && cfg.NetType != "udp4" && cfg.NetType != "udp6" {
 		return ErrInvalidNetType
 	}
 
@@ -35,6 +232,12 @@ func (cfg *Config) Validate() error {
 		return ErrInvalidConnHandler
 	}
 
+	if _, ok := cfg.Conn.(*net.UDPConn); cfg.Conn != nil && !ok {
+		if _, ok := cfg.ConnHandler.(PacketConnHandler); !ok {
+			return ErrInvalidConnHandler
+		}
+	}
+
 	if cfg.ReqHandler == nil {
 		return ErrInvalidReqHandler
 	}
@@ -43,6 +246,108 @@ func (cfg *Config) Validate() error {
 		return ErrInvalidRespHandler
 	}
 
+	for _, mw := range cfg.ReqMiddleware {
+		if mw == nil {
+			return ErrInvalidReqHandler
+		}
+	}
+
+	for _, mw := range cfg.RespMiddleware {
+		if mw == nil {
+			return ErrInvalidRespHandler
+		}
+	}
+
+	if cfg.MaxIPv4Size < 0 || cfg.MaxIPv4Size > MaxIPv4DatagramSize {
+		return ErrInvalidMaxSize
+	}
+
+	if cfg.MaxIPv6Size < 0 || cfg.MaxIPv6Size > MaxIPv6DatagramSize {
+		return ErrInvalidMaxSize
+	}
+
+	if cfg.OutboundBandwidthLimit < 0 || cfg.OutboundBurst < 0 {
+		return ErrInvalidBandwidth
+	}
+
+	if cfg.RateLimit != nil && (cfg.RateLimit.PerIPRate <= 0 || cfg.RateLimit.Burst < 0 || cfg.RateLimit.SweepInterval < 0 || cfg.RateLimit.MaxSources < 0 || cfg.RateLimit.NewSourceRate < 0 || cfg.RateLimit.NewSourceBurst < 0) {
+		return ErrInvalidRateLimit
+	}
+
+	if cfg.InboundBandwidthLimit < 0 || cfg.InboundBurst < 0 {
+		return ErrInvalidBandwidth
+	}
+
+	if cfg.InboundPacketLimit < 0 || cfg.InboundPacketBurst < 0 {
+		return ErrInvalidRateLimit
+	}
+
+	if cfg.CombineInterval < 0 || cfg.CombineMaxSize < 0 || cfg.CombineMaxSize > MaxIPv6DatagramSize {
+		return ErrInvalidCombine
+	}
+
+	if cfg.MaxWorkers < 0 || cfg.QueueDepth < 0 || cfg.QueuePolicy < QueueDropNew || cfg.QueuePolicy > QueueBlock {
+		return ErrInvalidWorkers
+	}
+
+	if cfg.MaxPacketSize < 0 || cfg.MaxPacketSize > MaxIPv6DatagramSize {
+		return ErrInvalidMaxSize
+	}
+
+	if cfg.ReadBufferSize < 0 || cfg.WriteBufferSize < 0 {
+		return ErrInvalidBufferSize
+	}
+
+	if cfg.TTL < 0 || cfg.TTL > 255 || cfg.TOS < 0 || cfg.TOS > 255 || cfg.TrafficClass < 0 || cfg.TrafficClass > 255 {
+		return ErrInvalidSockopt
+	}
+
+	if (cfg.TTL > 0 || cfg.TOS > 0 || cfg.TrafficClass > 0 || cfg.DontFragment) && !sockoptSupported {
+		return ErrSockoptUnsupported
+	}
+
+	if cfg.RequestTimeout < 0 {
+		return ErrInvalidRequestTimeout
+	}
+
+	if cfg.SessionTimeout < 0 {
+		return ErrInvalidSessionTimeout
+	}
+
+	if cfg.ShutdownTimeout < 0 {
+		return ErrInvalidShutdownTimeout
+	}
+
+	if cfg.OnDropLimit < 0 {
+		return ErrInvalidDropLimit
+	}
+
+	if cfg.FreeBind && !freeBindSupported {
+		return ErrFreeBindUnsupported
+	}
+
+	if cfg.MulticastGroup != "" {
+		if ip := net.ParseIP(cfg.MulticastGroup); ip == nil || !ip.IsMulticast() || cfg.FreeBind {
+			return ErrInvalidMulticast
+		}
+	}
+
+	if cfg.MulticastGroup == "" && cfg.MulticastInterface != "" {
+		return ErrInvalidMulticast
+	}
+
+	if cfg.ReusePort < 0 || (cfg.ReusePort > 1 && (cfg.Conn != nil || cfg.MulticastGroup != "")) {
+		return ErrInvalidReusePort
+	}
+
+	if cfg.ReusePort > 1 && !reusePortSupported {
+		return ErrReusePortUnsupported
+	}
+
+	if cfg.Broadcast && !broadcastSupported {
+		return ErrBroadcastUnsupported
+	}
+
 	return nil
 }
 
@@ -52,3 +357,224 @@ func (cfg *Config) Event(event string, format string, a ...interface{}) {
 		cfg.OptEvent.Event(event, format, a...)
 	}
 }
+
+// chain wraps the handlers in the middleware, the first middleware
+// outermost.
+func (cfg *Config) chain() {
+	for i := len(cfg.ReqMiddleware) - 1; i >= 0; i-- {
+		cfg.ReqHandler = cfg.ReqMiddleware[i](cfg.ReqHandler)
+	}
+
+	for i := len(cfg.RespMiddleware) - 1; i >= 0; i-- {
+		cfg.RespHandler = cfg.RespMiddleware[i](cfg.RespHandler)
+	}
+}
+
+// reportError hands a read or write failure to the OnError callback.
+func (cfg *Config) reportError(err error) {
+	if cfg.OnError != nil {
+		cfg.OnError(err)
+	}
+}
+
+// maxSize returns the largest datagram accepted from the address family.
+func (cfg *Config) maxSize(isIPv6 bool) int {
+	if isIPv6 {
+		if cfg.MaxIPv6Size == 0 {
+			return MaxIPv6DatagramSize
+		}
+		return cfg.MaxIPv6Size
+	}
+
+	if cfg.MaxIPv4Size == 0 {
+		return MaxIPv4DatagramSize
+	}
+	return cfg.MaxIPv4Size
+}
+
+// EffectiveConfig is the fully resolved configuration of a UDP value with
+// defaults applied and handlers represented by their type name. It can be
+// encoded as JSON for diagnostics.
+type EffectiveConfig struct {
+	NetType string `json:"net_type"`
+	Addr    string `json:"addr"`
+	Conn    string `json:"conn,omitempty"`
+
+	ConnHandler string `json:"conn_handler"`
+	ReqHandler  string `json:"req_handler"`
+	RespHandler string `json:"resp_handler"`
+
+	ReqMiddleware  int `json:"req_middleware"`
+	RespMiddleware int `json:"resp_middleware"`
+
+	MaxWorkers  int    `json:"max_workers"`
+	QueueDepth  int    `json:"queue_depth"`
+	QueuePolicy string `json:"queue_policy"`
+
+	Event          bool `json:"event"`
+	NewWorkerState bool `json:"new_worker_state"`
+	PanicHandler   bool `json:"panic_handler"`
+
+	MaxIPv4Size int  `json:"max_ipv4_size"`
+	MaxIPv6Size int  `json:"max_ipv6_size"`
+	DryRun      bool `json:"dry_run"`
+	FreeBind    bool `json:"free_bind"`
+
+	MulticastGroup     string `json:"multicast_group,omitempty"`
+	MulticastInterface string `json:"multicast_interface,omitempty"`
+	ReusePort          int    `json:"reuse_port"`
+	Broadcast          bool   `json:"broadcast"`
+
+	OutboundBandwidthLimit int              `json:"outbound_bandwidth_limit"`
+	OutboundBurst          int              `json:"outbound_burst"`
+	OutboundDrop           bool             `json:"outbound_drop"`
+	RateLimit              *RateLimitConfig `json:"rate_limit,omitempty"`
+	InboundBandwidthLimit  int              `json:"inbound_bandwidth_limit"`
+	InboundBurst           int              `json:"inbound_burst"`
+	InboundPacketLimit     int              `json:"inbound_packet_limit"`
+	InboundPacketBurst     int              `json:"inbound_packet_burst"`
+
+	CombineInterval string `json:"combine_interval"`
+	CombineMaxSize  int    `json:"combine_max_size"`
+
+	OnDrop      bool `json:"on_drop"`
+	OnDropLimit int  `json:"on_drop_limit"`
+	OnDone      bool `json:"on_done"`
+	OnStart     bool `json:"on_start"`
+	OnStop      bool `json:"on_stop"`
+	OnError     bool `json:"on_error"`
+
+	ShutdownTimeout string `json:"shutdown_timeout"`
+	RequestTimeout  string `json:"request_timeout"`
+
+	SessionTimeout string `json:"session_timeout"`
+	OnSessionClose bool   `json:"on_session_close"`
+
+	MaxPacketSize   int `json:"max_packet_size"`
+	ReadBufferSize  int `json:"read_buffer_size"`
+	WriteBufferSize int `json:"write_buffer_size"`
+
+	TTL          int  `json:"ttl"`
+	TOS          int  `json:"tos"`
+	TrafficClass int  `json:"traffic_class"`
+	DontFragment bool `json:"dont_fragment"`
+}
+
+// EffectiveConfig returns the resolved configuration the listener is
+// running with.
+func (d *UDP) EffectiveConfig() EffectiveConfig {
+	var rlc *RateLimitConfig
+	if d.RateLimit != nil {
+		resolved := d.RateLimit.resolved()
+		rlc = &resolved
+	}
+
+	return EffectiveConfig{
+		NetType: d.NetType,
+		Addr:    join(d.ipAddress, d.port),
+		Conn:    connType(d.Conn),
+
+		ConnHandler: fmt.Sprintf("%T", d.ConnHandler),
+		ReqHandler:  fmt.Sprintf("%T", d.ReqHandler),
+		RespHandler: fmt.Sprintf("%T", d.RespHandler),
+
+		ReqMiddleware:  len(d.ReqMiddleware),
+		RespMiddleware: len(d.RespMiddleware),
+
+		MaxWorkers:  d.MaxWorkers,
+		QueueDepth:  d.QueueDepth,
+		QueuePolicy: d.QueuePolicy.String(),
+
+		Event:          d.OptEvent.Event != nil,
+		NewWorkerState: d.NewWorkerState != nil,
+		PanicHandler:   d.PanicHandler != nil,
+
+		MaxIPv4Size: d.maxSize(false),
+		MaxIPv6Size: d.maxSize(true),
+		DryRun:      d.DryRun,
+		FreeBind:    d.FreeBind,
+
+		MulticastGroup:     d.MulticastGroup,
+		MulticastInterface: d.MulticastInterface,
+		ReusePort:          d.ReusePort,
+		Broadcast:          d.Broadcast,
+
+		OutboundBandwidthLimit: d.OutboundBandwidthLimit,
+		OutboundBurst:          burst(d.OutboundBandwidthLimit, d.OutboundBurst),
+		OutboundDrop:           d.OutboundDrop,
+		RateLimit:              rlc,
+		InboundBandwidthLimit:  d.InboundBandwidthLimit,
+		InboundBurst:           burst(d.InboundBandwidthLimit, d.InboundBurst),
+		InboundPacketLimit:     d.InboundPacketLimit,
+		InboundPacketBurst:     burst(d.InboundPacketLimit, d.InboundPacketBurst),
+
+		CombineInterval: d.CombineInterval.String(),
+		CombineMaxSize:  combineMaxSize(d.CombineInterval, d.CombineMaxSize),
+
+		OnDrop:      d.OnDrop != nil,
+		OnDropLimit: onDropLimit(d.OnDropLimit),
+		OnDone:      d.OnDone != nil,
+		OnStart:     d.OnStart != nil,
+		OnStop:      d.OnStop != nil,
+		OnError:     d.OnError != nil,
+
+		ShutdownTimeout: d.ShutdownTimeout.String(),
+		RequestTimeout:  d.RequestTimeout.String(),
+
+		SessionTimeout: d.SessionTimeout.String(),
+		OnSessionClose: d.OnSessionClose != nil,
+
+		MaxPacketSize:   maxPacketSize(d.MaxPacketSize),
+		ReadBufferSize:  d.ReadBufferSize,
+		WriteBufferSize: d.WriteBufferSize,
+
+		TTL:          d.TTL,
+		TOS:          d.TOS,
+		TrafficClass: d.TrafficClass,
+		DontFragment: d.DontFragment,
+	}
+}
+
+// burst returns the burst allowed for a bandwidth limit, which defaults to
+// one second of traffic.
+func burst(limit int, burst int) int {
+	if limit > 0 && burst == 0 {
+		return limit
+	}
+	return burst
+}
+
+// combineMaxSize returns the size of a combined datagram, which defaults
+// to MaxIPv4DatagramSize when combining is enabled.
+func combineMaxSize(interval time.Duration, size int) int {
+	if interval > 0 && size == 0 {
+		return MaxIPv4DatagramSize
+	}
+	return size
+}
+
+// maxPacketSize returns the size of the pooled read buffers, which
+// defaults to MaxIPv6DatagramSize.
+func maxPacketSize(size int) int {
+	if size == 0 {
+		return MaxIPv6DatagramSize
+	}
+	return size
+}
+
+// onDropLimit returns the number of drops reported per second, which
+// defaults to 100.
+func onDropLimit(limit int) int {
+	if limit == 0 {
+		return 100
+	}
+	return limit
+}
+
+// connType returns the type name of the connection provided by the user.
+func connType(conn net.PacketConn) string {
+	if conn == nil {
+		return ""
+	}
+	return fmt.Sprintf("%T", conn)
+}
@@ -17,6 +17,69 @@ func (udpConnHandler) Bind(listener *net.UDPConn) (io.Reader, io.Writer) {
 	return listener, listener
 }
 
+// packetIO adapts any net.PacketConn to the reader and writer handed to
+// the request and response handlers.
+type packetIO struct {
+	net.PacketConn
+}
+
+// Read reads a datagram without its source address.
+func (p packetIO) Read(b []byte) (int, error) {
+	n, _, err := p.ReadFrom(b)
+	return n, err
+}
+
+// Write is not supported since a datagram needs a destination.
+func (p packetIO) Write(b []byte) (int, error) {
+	return 0, io.ErrShortWrite
+}
+
+// packetConnHandler binds any net.PacketConn.
+type packetConnHandler struct{}
+
+// Bind is called to init to reader and writer.
+func (h packetConnHandler) Bind(listener *net.UDPConn) (io.Reader, io.Writer) {
+	return h.BindPacketConn(listener)
+}
+
+// BindPacketConn is called to init to reader and writer of a connection
+// provided through Config.Conn.
+func (packetConnHandler) BindPacketConn(listener net.PacketConn) (io.Reader, io.Writer) {
+	p := packetIO{listener}
+	return p, p
+}
+
+// packetReqHandler reads requests from any net.PacketConn.
+type packetReqHandler struct {
+	udpReqHandler
+}
+
+// Read implements the udp.ReqHandler interface.
+func (packetReqHandler) Read(reader io.Reader) (*net.UDPAddr, []byte, int, error) {
+	p := reader.(packetIO)
+
+	data := make([]byte, 20)
+	length, addr, err := p.ReadFrom(data)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	return addr.(*net.UDPAddr), data, length, nil
+}
+
+// packetRespHandler writes responses to any net.PacketConn.
+type packetRespHandler struct{}
+
+// Write is provided the user-defined writer and the data to write.
+func (packetRespHandler) Write(r *udp.Response, writer io.Writer) error {
+	p := writer.(packetIO)
+	if _, err := p.WriteTo(r.Data[:r.Length], r.UDPAddr); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // udpReqHandler is required to process client messages.
 type udpReqHandler struct{}
 
@@ -75,6 +138,129 @@ func (udpReqHandler) Process(r *udp.Request) {
 	atomic.StoreInt64(&dur, d)
 }
 
+// funcReqHandler reads like udpReqHandler but lets a test supply the
+// processing.
+type funcReqHandler struct {
+	udpReqHandler
+	process func(r *udp.Request)
+}
+
+// Process calls the test supplied function.
+func (h funcReqHandler) Process(r *udp.Request) {
+	h.process(r)
+}
+
+// slicedReqHandler returns the data read sliced to its length, keeping
+// the capacity of the buffer.
+type slicedReqHandler struct {
+	funcReqHandler
+}
+
+// Read reads like udpReqHandler and slices the data to the length.
+func (h slicedReqHandler) Read(reader io.Reader) (*net.UDPAddr, []byte, int, error) {
+	udpAddr, data, length, err := h.funcReqHandler.Read(reader)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	return udpAddr, data[:length], length, nil
+}
+
+// bufferReqHandler reads into the buffers pooled by the package.
+type bufferReqHandler struct {
+	funcReqHandler
+}
+
+// ReadBuffer implements the udp.BufferReqHandler interface.
+func (bufferReqHandler) ReadBuffer(reader io.Reader, buf []byte) (*net.UDPAddr, int, error) {
+	listener := reader.(*net.UDPConn)
+
+	length, udpAddr, err := listener.ReadFromUDP(buf)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return udpAddr, length, nil
+}
+
+// writerReqHandler writes its response into the package provided writer.
+type writerReqHandler struct {
+	udpReqHandler
+}
+
+// ProcessWriter writes the response in pieces.
+func (writerReqHandler) ProcessWriter(w *udp.ResponseWriter, r *udp.Request) {
+	io.WriteString(w, "GOT")
+	io.WriteString(w, " IT")
+}
+
+// tempError is a read error the listener recovers from.
+type tempError struct{}
+
+func (tempError) Error() string   { return "temporary read failure" }
+func (tempError) Temporary() bool { return true }
+
+// failingReqHandler fails its first read with a temporary error.
+type failingReqHandler struct {
+	udpReqHandler
+	failed *int32
+}
+
+// Read fails once before reading like udpReqHandler.
+func (h failingReqHandler) Read(reader io.Reader) (*net.UDPAddr, []byte, int, error) {
+	if atomic.CompareAndSwapInt32(h.failed, 0, 1) {
+		return nil, nil, 0, tempError{}
+	}
+
+	return h.udpReqHandler.Read(reader)
+}
+
+// rebindReqHandler closes the listener and takes its port on the first
+// read, so the listener can't be re-established after the read error.
+type rebindReqHandler struct {
+	udpReqHandler
+	blocker chan *net.UDPConn
+}
+
+// Read fails with the error of reading the closed listener.
+func (h rebindReqHandler) Read(reader io.Reader) (*net.UDPAddr, []byte, int, error) {
+	conn := reader.(*net.UDPConn)
+	conn.Close()
+
+	if blocker, err := net.ListenUDP("udp4", conn.LocalAddr().(*net.UDPAddr)); err == nil {
+		h.blocker <- blocker
+	}
+
+	_, _, err := conn.ReadFromUDP(make([]byte, 20))
+	return nil, nil, 0, err
+}
+
+// tagReqHandler is a middleware recording its tag before processing.
+type tagReqHandler struct {
+	udp.ReqHandler
+	tag  string
+	tags chan string
+}
+
+// Process records the tag and calls the wrapped handler.
+func (h tagReqHandler) Process(r *udp.Request) {
+	h.tags <- h.tag
+	h.ReqHandler.Process(r)
+}
+
+// tagRespHandler is a middleware recording its tag before writing.
+type tagRespHandler struct {
+	udp.RespHandler
+	tag  string
+	tags chan string
+}
+
+// Write records the tag and calls the wrapped handler.
+func (h tagRespHandler) Write(r *udp.Response, writer io.Writer) error {
+	h.tags <- h.tag
+	return h.RespHandler.Write(r, writer)
+}
+
 type udpRespHandler struct{}
 
 // Write is provided the user-defined writer and the data to write.
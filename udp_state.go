@@ -0,0 +1,97 @@
+package udp
+
+import "sync/atomic"
+
+// State represents a stage in the lifecycle of a UDP value.
+//
+// The valid transitions are:
+//
+//	StateCreated  -> StateStarted    New followed by a successful Start.
+//	StateStarted  -> StateDraining   StopWithContext, while the requests finish.
+//	StateStarted  -> StateStopped    Stop, or the listener ending on its own.
+//	StateDraining -> StateStopped    StopWithContext once the drain is over.
+//	StateStopped  -> StateStarted    A successful Start after the stop.
+//
+// The listener can't be paused, so there is no state between started and
+// draining.
+type State int32
+
+// Set of lifecycle states a UDP value can be in.
+const (
+	StateCreated State = iota
+	StateStarted
+	StateStopped
+	StateDraining
+)
+
+// String implements the fmt.Stringer interface.
+func (s State) String() string {
+	switch s {
+	case StateCreated:
+		return "created"
+	case StateStarted:
+		return "started"
+	case StateStopped:
+		return "stopped"
+	case StateDraining:
+		return "draining"
+	}
+	return "unknown"
+}
+
+// State returns the current lifecycle state.
+func (d *UDP) State() State {
+	return State(atomic.LoadInt32(&d.state))
+}
+
+// Subscribe returns a channel that receives every lifecycle state
+// transition from this point on. The channel holds only the latest
+// state, so a slow subscriber never blocks a transition but may miss
+// intermediate states. Call Unsubscribe to release the channel.
+func (d *UDP) Subscribe() <-chan State {
+	ch := make(chan State, 1)
+
+	d.subsMu.Lock()
+	{
+		d.subs = append(d.subs, ch)
+	}
+	d.subsMu.Unlock()
+
+	return ch
+}
+
+// Unsubscribe stops delivery of state transitions to a channel returned
+// by Subscribe and closes it.
+func (d *UDP) Unsubscribe(ch <-chan State) {
+	d.subsMu.Lock()
+	{
+		for i, sub := range d.subs {
+			if sub == ch {
+				d.subs = append(d.subs[:i], d.subs[i+1:]...)
+				close(sub)
+				break
+			}
+		}
+	}
+	d.subsMu.Unlock()
+}
+
+// setState records the new state and notifies all subscribers.
+func (d *UDP) setState(s State) {
+	atomic.StoreInt32(&d.state, int32(s))
+
+	d.subsMu.Lock()
+	{
+		for _, sub := range d.subs {
+
+			// Replace any state the subscriber has not read yet so
+			// the latest transition always wins.
+			select {
+			case <-sub:
+			default:
+			}
+			sub <- s
+		}
+	}
+	d.subsMu.Unlock()
+}
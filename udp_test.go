@@ -2,9 +2,19 @@ package udp_test
 
 import (
 	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
 	"io"
 	"net"
 	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -140,6 +150,3362 @@ func TestUDPAddr(t *testing.T) {
 	}
 }
 
+// TestUDPSubscribe validates lifecycle state transitions are delivered
+// to subscribers.
+func TestUDPSubscribe(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	t.Log("Given the need to be notified of lifecycle state changes.")
+	{
+		cfg := udp.Config{
+			NetType: "udp4",
+			Addr:    ":0",
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler:  udpReqHandler{},
+			RespHandler: udpRespHandler{},
+		}
+
+		u, err := udp.New("TEST", cfg)
+		if err != nil {
+			t.Fatal("\tShould be able to create a new UDP listener.", failed, err)
+		}
+		t.Log("\tShould be able to create a new UDP listener.", success)
+
+		if s := u.State(); s != udp.StateCreated {
+			t.Fatalf("\tShould be in the created state : %v %s", s, failed)
+		}
+		t.Log("\tShould be in the created state.", success)
+
+		ch := u.Subscribe()
+
+		if err := u.Start(); err != nil {
+			t.Fatal("\tShould be able to start the UDP listener.", failed, err)
+		}
+
+		if s := <-ch; s != udp.StateStarted {
+			t.Fatalf("\tShould receive the started state : %v %s", s, failed)
+		}
+		t.Log("\tShould receive the started state.", success)
+
+		if err := u.Stop(); err != nil {
+			t.Fatal("\tShould be able to stop the UDP listener.", failed, err)
+		}
+
+		if s := <-ch; s != udp.StateStopped {
+			t.Fatalf("\tShould receive the stopped state : %v %s", s, failed)
+		}
+		t.Log("\tShould receive the stopped state.", success)
+
+		if err := u.Start(); err != nil {
+			t.Fatal("\tShould be able to restart the UDP listener.", failed, err)
+		}
+		defer u.Stop()
+
+		if s := <-ch; s != udp.StateStarted {
+			t.Fatalf("\tShould receive the started state on restart : %v %s", s, failed)
+		}
+		t.Log("\tShould receive the started state on restart.", success)
+
+		u.Unsubscribe(ch)
+		if _, ok := <-ch; ok {
+			t.Fatal("\tShould close the channel on unsubscribe.", failed)
+		}
+		t.Log("\tShould close the channel on unsubscribe.", success)
+	}
+}
+
+// TestUDPWorkerState validates the per worker state is created once and
+// handed to each request.
+func TestUDPWorkerState(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	t.Log("Given the need to reuse per worker state across requests.")
+	{
+		var created int32
+		states := make(chan interface{}, 2)
+
+		cfg := udp.Config{
+			NetType: "udp4",
+			Addr:    ":0",
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler: funcReqHandler{
+				process: func(r *udp.Request) {
+					states <- r.WorkerState
+				},
+			},
+			RespHandler: udpRespHandler{},
+
+			NewWorkerState: func() interface{} {
+				atomic.AddInt32(&created, 1)
+				return new([20]byte)
+			},
+		}
+
+		u, err := udp.New("TEST", cfg)
+		if err != nil {
+			t.Fatal("\tShould be able to create a new UDP listener.", failed, err)
+		}
+
+		if err := u.Start(); err != nil {
+			t.Fatal("\tShould be able to start the UDP listener.", failed, err)
+		}
+		defer u.Stop()
+
+		conn, err := net.Dial("udp4", u.Addr().String())
+		if err != nil {
+			t.Fatal("\tShould be able to dial a new UDP connection.", failed, err)
+		}
+		defer conn.Close()
+
+		conn.Write([]byte("first"))
+		conn.Write([]byte("second"))
+
+		first, second := <-states, <-states
+		if first == nil || first != second {
+			t.Fatalf("\tShould receive the same state on each request : %v %v %s", first, second, failed)
+		}
+		t.Log("\tShould receive the same state on each request.", success)
+
+		if n := atomic.LoadInt32(&created); n != 1 {
+			t.Fatalf("\tShould create the state once : %d %s", n, failed)
+		}
+		t.Log("\tShould create the state once.", success)
+	}
+}
+
+// TestUDPMappedIPv4 validates IPv4 clients of a dual-stack listener are
+// presented with a plain IPv4 address.
+func TestUDPMappedIPv4(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	t.Log("Given the need to receive IPv4-mapped IPv6 sources as IPv4.")
+	{
+		reqs := make(chan *udp.Request, 1)
+
+		cfg := udp.Config{
+			NetType: "udp",
+			Addr:    "[::]:0",
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler: funcReqHandler{
+				process: func(r *udp.Request) {
+					reqs <- r
+				},
+			},
+			RespHandler: udpRespHandler{},
+		}
+
+		u, err := udp.New("TEST", cfg)
+		if err != nil {
+			t.Fatal("\tShould be able to create a new UDP listener.", failed, err)
+		}
+
+		// Skip when the host has no dual-stack support.
+		ln, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv6unspecified})
+		if err != nil {
+			t.Skip("\tDual-stack UDP is not available :", err)
+		}
+		ln.Close()
+
+		if err := u.Start(); err != nil {
+			t.Fatal("\tShould be able to start the UDP listener.", failed, err)
+		}
+		defer u.Stop()
+
+		_, port, _ := net.SplitHostPort(u.Addr().String())
+		conn, err := net.Dial("udp4", net.JoinHostPort("127.0.0.1", port))
+		if err != nil {
+			t.Fatal("\tShould be able to dial a new UDP connection.", failed, err)
+		}
+		defer conn.Close()
+
+		conn.Write([]byte("mapped"))
+
+		r := <-reqs
+		if len(r.UDPAddr.IP) != net.IPv4len || r.IsIPv6 {
+			t.Fatalf("\tShould receive a plain IPv4 address : %v %s", r.UDPAddr.IP, failed)
+		}
+		t.Log("\tShould receive a plain IPv4 address.", success)
+
+		if !r.UDPAddr.IP.Equal(net.IPv4(127, 0, 0, 1)) {
+			t.Fatalf("\tShould receive the loopback address : %v %s", r.UDPAddr.IP, failed)
+		}
+		t.Log("\tShould receive the loopback address.", success)
+	}
+}
+
+// TestUDPMaxSize validates oversized datagrams are rejected per address
+// family.
+func TestUDPMaxSize(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	tests := []struct {
+		netType string
+		addr    string
+		max4    int
+		max6    int
+	}{
+		{"udp4", "127.0.0.1:0", 10, 0},
+		{"udp6", "[::1]:0", 0, 10},
+	}
+
+	t.Log("Given the need to reject datagrams larger than the address family allows.")
+	{
+		for _, tt := range tests {
+			t.Logf("\tWhen using %s", tt.netType)
+			{
+				lengths := make(chan int, 2)
+
+				cfg := udp.Config{
+					NetType: tt.netType,
+					Addr:    tt.addr,
+
+					ConnHandler: udpConnHandler{},
+					ReqHandler: funcReqHandler{
+						process: func(r *udp.Request) {
+							lengths <- r.Length
+						},
+					},
+					RespHandler: udpRespHandler{},
+
+					MaxIPv4Size: tt.max4,
+					MaxIPv6Size: tt.max6,
+				}
+
+				// Skip when the host does not support the address family.
+				ln, err := net.ListenPacket(tt.netType, tt.addr)
+				if err != nil {
+					t.Logf("\t\t%s is not available : %v", tt.netType, err)
+					continue
+				}
+				ln.Close()
+
+				u, err := udp.New("TEST", cfg)
+				if err != nil {
+					t.Fatal("\t\tShould be able to create a new UDP listener.", failed, err)
+				}
+
+				if err := u.Start(); err != nil {
+					t.Fatal("\t\tShould be able to start the UDP listener.", failed, err)
+				}
+
+				conn, err := net.Dial(tt.netType, u.Addr().String())
+				if err != nil {
+					t.Fatal("\t\tShould be able to dial a new UDP connection.", failed, err)
+				}
+
+				conn.Write(make([]byte, 15))
+				conn.Write(make([]byte, 5))
+
+				if n := <-lengths; n != 5 {
+					t.Errorf("\t\tShould only process the datagram within the limit : %d %s", n, failed)
+				} else {
+					t.Log("\t\tShould only process the datagram within the limit.", success)
+				}
+
+				conn.Close()
+				u.Stop()
+			}
+		}
+	}
+}
+
+// TestUDPMaxSizeValidate validates size limits beyond what the address
+// family can carry are rejected.
+func TestUDPMaxSizeValidate(t *testing.T) {
+	t.Log("Given the need to validate the datagram size limits.")
+	{
+		cfg := udp.Config{
+			NetType: "udp4",
+			Addr:    ":0",
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler:  udpReqHandler{},
+			RespHandler: udpRespHandler{},
+
+			MaxIPv4Size: udp.MaxIPv4DatagramSize + 1,
+		}
+
+		if err := cfg.Validate(); err != udp.ErrInvalidMaxSize {
+			t.Fatalf("\tShould reject an IPv4 limit beyond %d : %v %s", udp.MaxIPv4DatagramSize, err, failed)
+		}
+		t.Logf("\tShould reject an IPv4 limit beyond %d. %s", udp.MaxIPv4DatagramSize, success)
+	}
+}
+
+// TestUDPDryRun validates a dry run listener binds but drops every
+// datagram.
+func TestUDPDryRun(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	t.Log("Given the need to validate a listener without serving traffic.")
+	{
+		processed := make(chan struct{}, 1)
+		events := make(chan string, 10)
+
+		cfg := udp.Config{
+			NetType: "udp4",
+			Addr:    "127.0.0.1:0",
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler: funcReqHandler{
+				process: func(r *udp.Request) {
+					processed <- struct{}{}
+				},
+			},
+			RespHandler: udpRespHandler{},
+
+			OptEvent: udp.OptEvent{
+				Event: func(event string, format string, a ...interface{}) {
+					events <- fmt.Sprintf(format, a...)
+				},
+			},
+
+			DryRun: true,
+		}
+
+		u, err := udp.New("TEST", cfg)
+		if err != nil {
+			t.Fatal("\tShould be able to create a new UDP listener.", failed, err)
+		}
+
+		if err := u.Start(); err != nil {
+			t.Fatal("\tShould be able to start the UDP listener.", failed, err)
+		}
+		t.Log("\tShould be able to bind the address.", success)
+
+		conn, err := net.Dial("udp4", u.Addr().String())
+		if err != nil {
+			t.Fatal("\tShould be able to dial a new UDP connection.", failed, err)
+		}
+		defer conn.Close()
+
+		conn.Write([]byte("dry run"))
+
+		for msg := range events {
+			if strings.HasPrefix(msg, "Dry Run Dropped") {
+				t.Log("\tShould drop the datagram.", success)
+				break
+			}
+		}
+
+		if err := u.Stop(); err != nil {
+			t.Fatal("\tShould be able to stop the UDP listener.", failed, err)
+		}
+
+		select {
+		case <-processed:
+			t.Fatal("\tShould not process the datagram.", failed)
+		default:
+			t.Log("\tShould not process the datagram.", success)
+		}
+
+		if sd := u.Stats(); sd.DryRunDrops != 1 || sd.Drops != 1 {
+			t.Fatalf("\tShould count the datagram dropped : %+v %s", sd, failed)
+		}
+		t.Log("\tShould count the datagram dropped.", success)
+	}
+}
+
+// TestUDPFreeBind validates a listener can bind an address that is not
+// configured on the host.
+func TestUDPFreeBind(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("IP_FREEBIND is only supported on linux")
+	}
+
+	resetLog()
+	defer displayLog()
+
+	t.Log("Given the need to bind an address not assigned to the host.")
+	{
+		cfg := udp.Config{
+			NetType: "udp4",
+			Addr:    "192.0.2.1:0", // TEST-NET-1, never assigned locally.
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler:  udpReqHandler{},
+			RespHandler: udpRespHandler{},
+
+			FreeBind: true,
+		}
+
+		u, err := udp.New("TEST", cfg)
+		if err != nil {
+			t.Fatal("\tShould be able to create a new UDP listener.", failed, err)
+		}
+
+		if err := u.Start(); err != nil {
+			t.Fatal("\tShould be able to start the UDP listener.", failed, err)
+		}
+		defer u.Stop()
+
+		host, _, _ := net.SplitHostPort(u.Addr().String())
+		if host != "192.0.2.1" {
+			t.Fatalf("\tShould be bound to the non-local address : %s %s", host, failed)
+		}
+		t.Log("\tShould be bound to the non-local address.", success)
+	}
+}
+
+// TestUDPReusePort validates datagrams are read by several sockets bound
+// to the same address.
+func TestUDPReusePort(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("SO_REUSEPORT is only supported on linux")
+	}
+
+	resetLog()
+	defer displayLog()
+
+	const (
+		sockets = 4
+		clients = 32
+	)
+
+	t.Log("Given the need to spread the reads across several sockets.")
+	{
+		var states int32
+		var mu sync.Mutex
+		readers := make(map[interface{}]bool)
+		done := make(chan struct{}, clients)
+
+		cfg := udp.Config{
+			NetType: "udp4",
+			Addr:    "127.0.0.1:0",
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler: funcReqHandler{
+				process: func(r *udp.Request) {
+					mu.Lock()
+					readers[r.WorkerState] = true
+					mu.Unlock()
+
+					done <- struct{}{}
+				},
+			},
+			RespHandler: udpRespHandler{},
+
+			// Each reading routine gets its own state, which identifies it.
+			NewWorkerState: func() interface{} {
+				return atomic.AddInt32(&states, 1)
+			},
+
+			ReusePort: sockets,
+		}
+
+		u, err := udp.New("TEST", cfg)
+		if err != nil {
+			t.Fatal("\tShould be able to create a new UDP listener.", failed, err)
+		}
+
+		if err := u.Start(); err != nil {
+			t.Fatal("\tShould be able to start the UDP listener.", failed, err)
+		}
+		t.Logf("\tShould be able to bind %d sockets to %s. %s", sockets, u.Addr(), success)
+
+		// Each client has its own source port, which the kernel hashes to
+		// pick the socket.
+		for i := 0; i < clients; i++ {
+			conn, err := net.Dial("udp4", u.Addr().String())
+			if err != nil {
+				t.Fatal("\tShould be able to dial a new UDP connection.", failed, err)
+			}
+			conn.Write(make([]byte, 20))
+			conn.Close()
+		}
+
+		for i := 0; i < clients; i++ {
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatalf("\tShould process every datagram : %+v %s", u.Stats(), failed)
+			}
+		}
+		t.Log("\tShould process every datagram.", success)
+
+		mu.Lock()
+		n := len(readers)
+		mu.Unlock()
+
+		if n < 2 {
+			t.Errorf("\tShould read datagrams on more than one socket : %d %s", n, failed)
+		} else {
+			t.Logf("\tShould read datagrams on more than one socket : %d %s", n, success)
+		}
+
+		if err := u.Stop(); err != nil {
+			t.Fatal("\tShould be able to stop every socket.", failed, err)
+		}
+		t.Log("\tShould be able to stop every socket.", success)
+	}
+}
+
+// TestUDPSocketOptions validates the IP options are applied to the socket
+// and reported back.
+func TestUDPSocketOptions(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("socket options are only supported on linux")
+	}
+
+	resetLog()
+	defer displayLog()
+
+	tests := []struct {
+		netType string
+		addr    string
+	}{
+		{"udp4", "127.0.0.1:0"},
+		{"udp6", "[::1]:0"},
+	}
+
+	t.Log("Given the need to tune the socket of the listener.")
+	{
+		for _, tt := range tests {
+			t.Logf("\tWhen using %s", tt.netType)
+			{
+				cfg := udp.Config{
+					NetType: tt.netType,
+					Addr:    tt.addr,
+
+					ConnHandler: udpConnHandler{},
+					ReqHandler:  udpReqHandler{},
+					RespHandler: udpRespHandler{},
+
+					ReadBufferSize: 1 << 16,
+					TTL:            7,
+					TOS:            0x28,
+					TrafficClass:   0x28,
+					DontFragment:   true,
+				}
+
+				u, err := udp.New("TEST", cfg)
+				if err != nil {
+					t.Fatal("\t\tShould be able to create a new UDP listener.", failed, err)
+				}
+
+				if err := u.Start(); err != nil {
+					t.Skip("\t\tNo loopback address for the family.", err)
+				}
+
+				so, err := u.SocketOptions()
+				u.Stop()
+
+				if err != nil {
+					t.Fatal("\t\tShould be able to read the socket options.", failed, err)
+				}
+
+				if so.TTL != 7 || so.TOS+so.TrafficClass != 0x28 || !so.DontFragment || so.ReadBufferSize < 1<<16 {
+					t.Errorf("\t\tShould report the options applied : %+v %s", so, failed)
+					continue
+				}
+				t.Logf("\t\tShould report the options applied : %+v %s", so, success)
+			}
+		}
+	}
+}
+
+// TestUDPMulticast validates a listener joined to a multicast group
+// receives the datagrams sent to the group.
+func TestUDPMulticast(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	t.Log("Given the need to consume a multicast stream.")
+	{
+		reqs := make(chan udp.Request, 1)
+
+		cfg := udp.Config{
+			NetType: "udp4",
+			Addr:    ":0",
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler: funcReqHandler{
+				process: func(r *udp.Request) {
+					reqs <- *r
+				},
+			},
+			RespHandler: udpRespHandler{},
+
+			MulticastGroup: "239.1.2.3",
+			Broadcast:      true,
+		}
+
+		u, err := udp.New("TEST", cfg)
+		if err != nil {
+			t.Fatal("\tShould be able to create a new UDP listener.", failed, err)
+		}
+
+		if err := u.Start(); err != nil {
+			t.Fatal("\tShould be able to join the multicast group.", failed, err)
+		}
+		t.Log("\tShould be able to join the multicast group.", success)
+		defer u.Stop()
+
+		_, port, _ := net.SplitHostPort(u.Addr().String())
+		conn, err := net.Dial("udp4", net.JoinHostPort("239.1.2.3", port))
+		if err != nil {
+			t.Fatal("\tShould be able to dial the multicast group.", failed, err)
+		}
+		defer conn.Close()
+
+		if _, err := conn.Write([]byte("telemetry")); err != nil {
+			t.Fatal("\tShould be able to send to the multicast group.", failed, err)
+		}
+
+		select {
+		case r := <-reqs:
+			if string(r.Data[:r.Length]) != "telemetry" {
+				t.Fatalf("\tShould receive the datagram sent to the group : %q %s", r.Data[:r.Length], failed)
+			}
+			t.Log("\tShould receive the datagram sent to the group.", success)
+		case <-time.After(time.Second):
+			t.Skip("\tNo multicast route to loop the datagram back to the host.")
+		}
+	}
+
+	t.Log("Given the need to reject an invalid multicast group.")
+	{
+		cfg := udp.Config{
+			NetType: "udp4",
+			Addr:    ":0",
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler:  udpReqHandler{},
+			RespHandler: udpRespHandler{},
+
+			MulticastGroup: "192.0.2.1",
+		}
+
+		if _, err := udp.New("TEST", cfg); err != udp.ErrInvalidMulticast {
+			t.Fatalf("\tShould reject a unicast group address : %v %s", err, failed)
+		}
+		t.Log("\tShould reject a unicast group address.", success)
+	}
+}
+
+// TestUDPOutboundBandwidth validates sends are held under the outbound
+// bandwidth cap.
+func TestUDPOutboundBandwidth(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	t.Log("Given the need to cap the outbound bandwidth.")
+	{
+		tests := []struct {
+			name string
+			drop bool
+		}{
+			{"delayed", false},
+			{"dropped", true},
+		}
+
+		for _, tt := range tests {
+			t.Logf("\tWhen sends over the cap are %s", tt.name)
+			{
+				cfg := udp.Config{
+					NetType: "udp4",
+					Addr:    "127.0.0.1:0",
+
+					ConnHandler: udpConnHandler{},
+					ReqHandler:  udpReqHandler{},
+					RespHandler: udpRespHandler{},
+
+					OutboundBandwidthLimit: 1000,
+					OutboundBurst:          100,
+					OutboundDrop:           tt.drop,
+				}
+
+				u, err := udp.New("TEST", cfg)
+				if err != nil {
+					t.Fatal("\t\tShould be able to create a new UDP listener.", failed, err)
+				}
+
+				if err := u.Start(); err != nil {
+					t.Fatal("\t\tShould be able to start the UDP listener.", failed, err)
+				}
+
+				resp := udp.Response{
+					UDPAddr: u.Addr().(*net.UDPAddr),
+					Data:    make([]byte, 100),
+					Length:  100,
+				}
+
+				if err := u.Send(&resp); err != nil {
+					t.Fatal("\t\tShould be able to send the burst.", failed, err)
+				}
+				t.Log("\t\tShould be able to send the burst.", success)
+
+				start := time.Now()
+				err = u.Send(&resp)
+				elapsed := time.Since(start)
+
+				if tt.drop {
+					if err != udp.ErrBandwidthExceeded {
+						t.Errorf("\t\tShould drop the send over the cap : %v %s", err, failed)
+					} else {
+						t.Log("\t\tShould drop the send over the cap.", success)
+					}
+				} else {
+					if err != nil || elapsed < 50*time.Millisecond {
+						t.Errorf("\t\tShould delay the send over the cap : %v %v %s", err, elapsed, failed)
+					} else {
+						t.Log("\t\tShould delay the send over the cap.", success)
+					}
+				}
+
+				want := 200.0
+				if tt.drop {
+					want = 100
+				}
+
+				if rate := u.Stats().WriteRate; rate != want {
+					t.Errorf("\t\tShould report the bytes sent in the last second : %v %s", rate, failed)
+				} else {
+					t.Log("\t\tShould report the bytes sent in the last second.", success)
+				}
+
+				u.Stop()
+			}
+		}
+	}
+}
+
+// TestUDPInboundBandwidth validates datagrams over the inbound bandwidth
+// cap are dropped.
+func TestUDPInboundBandwidth(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	t.Log("Given the need to shed datagrams over the inbound bandwidth cap.")
+	{
+		lengths := make(chan int, 3)
+		events := make(chan string, 10)
+
+		cfg := udp.Config{
+			NetType: "udp4",
+			Addr:    "127.0.0.1:0",
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler: funcReqHandler{
+				process: func(r *udp.Request) {
+					lengths <- r.Length
+				},
+			},
+			RespHandler: udpRespHandler{},
+
+			OptEvent: udp.OptEvent{
+				Event: func(event string, format string, a ...interface{}) {
+					events <- fmt.Sprintf(format, a...)
+				},
+			},
+
+			InboundBandwidthLimit: 10,
+			InboundBurst:          20,
+		}
+
+		u, err := udp.New("TEST", cfg)
+		if err != nil {
+			t.Fatal("\tShould be able to create a new UDP listener.", failed, err)
+		}
+
+		if err := u.Start(); err != nil {
+			t.Fatal("\tShould be able to start the UDP listener.", failed, err)
+		}
+		defer u.Stop()
+
+		conn, err := net.Dial("udp4", u.Addr().String())
+		if err != nil {
+			t.Fatal("\tShould be able to dial a new UDP connection.", failed, err)
+		}
+		defer conn.Close()
+
+		conn.Write(make([]byte, 20))
+		conn.Write(make([]byte, 20))
+
+		if n := <-lengths; n != 20 {
+			t.Fatalf("\tShould process the burst : %d %s", n, failed)
+		}
+		t.Log("\tShould process the burst.", success)
+
+		for msg := range events {
+			if strings.HasPrefix(msg, "Bandwidth Dropped") {
+				t.Log("\tShould drop the datagram over the cap.", success)
+				break
+			}
+		}
+
+		if sd := u.Stats(); sd.InboundDrops != 1 || sd.Drops != 1 {
+			t.Fatalf("\tShould count the datagram dropped : %+v %s", sd, failed)
+		}
+		t.Log("\tShould count the datagram dropped.", success)
+
+		if rate := u.Stats().RecvRate; rate != 40 {
+			t.Fatalf("\tShould report the bytes read in the last second : %v %s", rate, failed)
+		}
+		t.Log("\tShould report the bytes read in the last second.", success)
+	}
+}
+
+// TestUDPEffectiveConfig validates the resolved configuration reports the
+// defaults that were applied.
+func TestUDPEffectiveConfig(t *testing.T) {
+	t.Log("Given the need to inspect the configuration a listener runs with.")
+	{
+		cfg := udp.Config{
+			NetType: "udp4",
+			Addr:    "127.0.0.1:9000",
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler:  udpReqHandler{},
+			RespHandler: udpRespHandler{},
+
+			InboundBandwidthLimit: 1000,
+		}
+
+		u, err := udp.New("TEST", cfg)
+		if err != nil {
+			t.Fatal("\tShould be able to create a new UDP listener.", failed, err)
+		}
+
+		ec := u.EffectiveConfig()
+
+		if ec.ReqHandler != "udp_test.udpReqHandler" {
+			t.Errorf("\tShould name the request handler type : %s %s", ec.ReqHandler, failed)
+		} else {
+			t.Log("\tShould name the request handler type.", success)
+		}
+
+		if ec.MaxIPv4Size != udp.MaxIPv4DatagramSize || ec.InboundBurst != 1000 {
+			t.Errorf("\tShould apply the defaults : %+v %s", ec, failed)
+		} else {
+			t.Log("\tShould apply the defaults.", success)
+		}
+
+		if _, err := json.Marshal(ec); err != nil {
+			t.Errorf("\tShould be able to encode as JSON : %v %s", err, failed)
+		} else {
+			t.Log("\tShould be able to encode as JSON.", success)
+		}
+	}
+}
+
+// TestUDPPanicRecover validates a panic in Process is recovered by default
+// and the listener keeps processing.
+func TestUDPPanicRecover(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	t.Log("Given the need to recover from a panic while processing.")
+	{
+		lengths := make(chan int, 1)
+
+		cfg := udp.Config{
+			NetType: "udp4",
+			Addr:    "127.0.0.1:0",
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler: funcReqHandler{
+				process: func(r *udp.Request) {
+					if r.Length == 1 {
+						panic("bad datagram")
+					}
+					lengths <- r.Length
+				},
+			},
+			RespHandler: udpRespHandler{},
+		}
+
+		u, err := udp.New("TEST", cfg)
+		if err != nil {
+			t.Fatal("\tShould be able to create a new UDP listener.", failed, err)
+		}
+
+		if err := u.Start(); err != nil {
+			t.Fatal("\tShould be able to start the UDP listener.", failed, err)
+		}
+		defer u.Stop()
+
+		conn, err := net.Dial("udp4", u.Addr().String())
+		if err != nil {
+			t.Fatal("\tShould be able to dial a new UDP connection.", failed, err)
+		}
+		defer conn.Close()
+
+		conn.Write([]byte{1})
+		conn.Write([]byte{1, 2})
+
+		if n := <-lengths; n != 2 {
+			t.Fatalf("\tShould process the datagram after the panic : %d %s", n, failed)
+		}
+		t.Log("\tShould process the datagram after the panic.", success)
+	}
+}
+
+// TestUDPPanicPropagate validates the panic handler can choose to crash
+// the process. The listener runs in a child process so the crash can be
+// observed.
+func TestUDPPanicPropagate(t *testing.T) {
+	if os.Getenv("UDP_TEST_PANIC") == "1" {
+		cfg := udp.Config{
+			NetType: "udp4",
+			Addr:    "127.0.0.1:0",
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler: funcReqHandler{
+				process: func(r *udp.Request) {
+					panic("fatal datagram")
+				},
+			},
+			RespHandler: udpRespHandler{},
+
+			PanicHandler: func(r *udp.Request, v interface{}) bool {
+				return true
+			},
+		}
+
+		u, err := udp.New("TEST", cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := u.Start(); err != nil {
+			t.Fatal(err)
+		}
+
+		conn, err := net.Dial("udp4", u.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		conn.Write([]byte{1})
+		time.Sleep(5 * time.Second)
+		return
+	}
+
+	t.Log("Given the need to crash the process on a fatal panic.")
+	{
+		cmd := exec.Command(os.Args[0], "-test.run=^TestUDPPanicPropagate$")
+		cmd.Env = append(os.Environ(), "UDP_TEST_PANIC=1")
+
+		out, err := cmd.CombinedOutput()
+		if _, ok := err.(*exec.ExitError); !ok {
+			t.Fatalf("\tShould crash the process : %v %s", err, failed)
+		}
+		t.Log("\tShould crash the process.", success)
+
+		if !bytes.Contains(out, []byte("panic: fatal datagram")) {
+			t.Fatalf("\tShould report the original panic : %s %s", out, failed)
+		}
+		t.Log("\tShould report the original panic.", success)
+	}
+}
+
+// TestUDPBindError validates bind failures are classified.
+func TestUDPBindError(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	newUDP := func(addr string) *udp.UDP {
+		cfg := udp.Config{
+			NetType: "udp4",
+			Addr:    addr,
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler:  udpReqHandler{},
+			RespHandler: udpRespHandler{},
+		}
+
+		u, err := udp.New("TEST", cfg)
+		if err != nil {
+			t.Fatal("\tShould be able to create a new UDP listener.", failed, err)
+		}
+		return u
+	}
+
+	t.Log("Given the need to diagnose bind failures.")
+	{
+		t.Log("\tWhen the address is already in use")
+		{
+			first := newUDP("127.0.0.1:0")
+			if err := first.Start(); err != nil {
+				t.Fatal("\t\tShould be able to start the UDP listener.", failed, err)
+			}
+			defer first.Stop()
+
+			err := newUDP(first.Addr().String()).Start()
+			if _, ok := err.(*udp.BindError); !ok || !errors.Is(err, udp.ErrBindAddrInUse) {
+				t.Errorf("\t\tShould classify the failure as address in use : %v %s", err, failed)
+			} else {
+				t.Logf("\t\tShould classify the failure as address in use : %v %s", err, success)
+			}
+		}
+
+		t.Log("\tWhen the address is not local")
+		{
+			err := newUDP("192.0.2.1:0").Start()
+			if !errors.Is(err, udp.ErrBindAddrNotAvailable) {
+				t.Errorf("\t\tShould classify the failure as address not available : %v %s", err, failed)
+			} else {
+				t.Logf("\t\tShould classify the failure as address not available : %v %s", err, success)
+			}
+		}
+
+		t.Log("\tWhen binding a privileged port")
+		{
+			u := newUDP("127.0.0.1:1")
+			err := u.Start()
+			if err == nil {
+				u.Stop()
+				t.Log("\t\tPrivileged ports can be bound by this process.")
+			} else if !errors.Is(err, udp.ErrBindPermission) || !strings.Contains(err.Error(), "below 1024") {
+				t.Errorf("\t\tShould classify the failure as permission denied : %v %s", err, failed)
+			} else {
+				t.Logf("\t\tShould classify the failure as permission denied : %v %s", err, success)
+			}
+		}
+
+		t.Log("\tWhen the listener can't be re-established after a read error")
+		{
+			probe := newUDP("127.0.0.1:0")
+			if err := probe.Start(); err != nil {
+				t.Fatal("\t\tShould be able to start the UDP listener.", failed, err)
+			}
+			addr := probe.Addr().String()
+			probe.Stop()
+
+			blocker := make(chan *net.UDPConn, 1)
+			errs := make(chan error, 2)
+			stopped := make(chan error, 1)
+
+			cfg := udp.Config{
+				NetType: "udp4",
+				Addr:    addr,
+
+				ConnHandler: udpConnHandler{},
+				ReqHandler:  rebindReqHandler{blocker: blocker},
+				RespHandler: udpRespHandler{},
+
+				OnError: func(err error) { errs <- err },
+				OnStop:  func(err error) { stopped <- err },
+			}
+
+			u, err := udp.New("TEST", cfg)
+			if err != nil {
+				t.Fatal("\t\tShould be able to create a new UDP listener.", failed, err)
+			}
+
+			if err := u.Start(); err != nil {
+				t.Fatal("\t\tShould be able to start the UDP listener.", failed, err)
+			}
+
+			select {
+			case err := <-stopped:
+				if !errors.Is(err, udp.ErrBindAddrInUse) {
+					t.Fatalf("\t\tShould stop the listener with the bind failure : %v %s", err, failed)
+				}
+				t.Logf("\t\tShould stop the listener with the bind failure : %v %s", err, success)
+			case <-time.After(time.Second):
+				t.Fatal("\t\tShould stop the listener with the bind failure.", failed)
+			}
+			(<-blocker).Close()
+
+			<-errs
+			if err := <-errs; !errors.Is(err, udp.ErrBindAddrInUse) {
+				t.Fatalf("\t\tShould report the bind failure to OnError : %v %s", err, failed)
+			}
+			t.Log("\t\tShould report the bind failure to OnError.", success)
+
+			if u.State() != udp.StateStopped {
+				t.Fatalf("\t\tShould move to the stopped state : %v %s", u.State(), failed)
+			}
+			t.Log("\t\tShould move to the stopped state.", success)
+		}
+	}
+}
+
+// TestUDPCombine validates responses to the same destination are combined
+// into a single framed datagram.
+func TestUDPCombine(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	t.Log("Given the need to combine responses written within an interval.")
+	{
+		cfg := udp.Config{
+			NetType: "udp4",
+			Addr:    "127.0.0.1:0",
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler:  udpReqHandler{},
+			RespHandler: udpRespHandler{},
+
+			CombineInterval: 200 * time.Millisecond,
+		}
+
+		u, err := udp.New("TEST", cfg)
+		if err != nil {
+			t.Fatal("\tShould be able to create a new UDP listener.", failed, err)
+		}
+
+		if err := u.Start(); err != nil {
+			t.Fatal("\tShould be able to start the UDP listener.", failed, err)
+		}
+		defer u.Stop()
+
+		client, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			t.Fatal("\tShould be able to open a client socket.", failed, err)
+		}
+		defer client.Close()
+
+		msgs := []string{"one", "two", "three"}
+		for _, msg := range msgs {
+			resp := udp.Response{
+				UDPAddr: client.LocalAddr().(*net.UDPAddr),
+				Data:    []byte(msg),
+				Length:  len(msg),
+			}
+			if err := u.Send(&resp); err != nil {
+				t.Fatal("\tShould be able to send the response.", failed, err)
+			}
+		}
+
+		client.SetReadDeadline(time.Now().Add(2 * time.Second))
+		data := make([]byte, 1024)
+		n, err := client.Read(data)
+		if err != nil {
+			t.Fatal("\tShould receive the combined datagram.", failed, err)
+		}
+		t.Log("\tShould receive the combined datagram.", success)
+
+		var got []string
+		for b := data[:n]; len(b) >= 2; {
+			l := int(binary.BigEndian.Uint16(b))
+			got = append(got, string(b[2:2+l]))
+			b = b[2+l:]
+		}
+
+		if strings.Join(got, ",") != strings.Join(msgs, ",") {
+			t.Fatalf("\tShould decode every response : %v %s", got, failed)
+		}
+		t.Log("\tShould decode every response.", success)
+
+		if cs := u.CombineStats(); cs.Responses != 3 || cs.Datagrams != 1 {
+			t.Fatalf("\tShould count the combined work : %+v %s", cs, failed)
+		}
+		t.Log("\tShould count the combined work.", success)
+
+		large := udp.Response{
+			UDPAddr: client.LocalAddr().(*net.UDPAddr),
+			Data:    make([]byte, udp.MaxIPv4DatagramSize-1),
+			Length:  udp.MaxIPv4DatagramSize - 1,
+		}
+		if err := u.Send(&large); err != udp.ErrResponseTooLarge {
+			t.Fatalf("\tShould reject a response too large to combine : %v %s", err, failed)
+		}
+		if cs := u.CombineStats(); cs.Responses != 3 {
+			t.Fatalf("\tShould not count the response rejected : %+v %s", cs, failed)
+		}
+		t.Log("\tShould reject a response too large to combine.", success)
+	}
+}
+
+// TestUDPLastRecv validates the time of the last datagram is recorded.
+func TestUDPLastRecv(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	t.Log("Given the need to know when the last datagram arrived.")
+	{
+		reqs := make(chan *udp.Request, 1)
+
+		cfg := udp.Config{
+			NetType: "udp4",
+			Addr:    "127.0.0.1:0",
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler: funcReqHandler{
+				process: func(r *udp.Request) {
+					reqs <- r
+				},
+			},
+			RespHandler: udpRespHandler{},
+		}
+
+		u, err := udp.New("TEST", cfg)
+		if err != nil {
+			t.Fatal("\tShould be able to create a new UDP listener.", failed, err)
+		}
+
+		if err := u.Start(); err != nil {
+			t.Fatal("\tShould be able to start the UDP listener.", failed, err)
+		}
+		defer u.Stop()
+
+		if !u.LastRecvAt().IsZero() || u.SinceLastRecv() != 0 {
+			t.Fatal("\tShould report nothing before a datagram arrives.", failed)
+		}
+		t.Log("\tShould report nothing before a datagram arrives.", success)
+
+		conn, err := net.Dial("udp4", u.Addr().String())
+		if err != nil {
+			t.Fatal("\tShould be able to dial a new UDP connection.", failed, err)
+		}
+		defer conn.Close()
+
+		conn.Write([]byte("alive"))
+		r := <-reqs
+
+		if !u.LastRecvAt().Equal(r.ReadAt) {
+			t.Fatalf("\tShould record the arrival time : %v %v %s", u.LastRecvAt(), r.ReadAt, failed)
+		}
+		t.Log("\tShould record the arrival time.", success)
+
+		if u.SinceLastRecv() <= 0 {
+			t.Fatal("\tShould report the time since the arrival.", failed)
+		}
+		t.Log("\tShould report the time since the arrival.", success)
+	}
+}
+
+// TestUDPResponseWriter validates a handler can write its response into
+// the package provided writer.
+func TestUDPResponseWriter(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	t.Log("Given the need to write responses without building a buffer.")
+	{
+		cfg := udp.Config{
+			NetType: "udp4",
+			Addr:    "127.0.0.1:0",
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler:  writerReqHandler{},
+			RespHandler: udpRespHandler{},
+		}
+
+		u, err := udp.New("TEST", cfg)
+		if err != nil {
+			t.Fatal("\tShould be able to create a new UDP listener.", failed, err)
+		}
+
+		if err := u.Start(); err != nil {
+			t.Fatal("\tShould be able to start the UDP listener.", failed, err)
+		}
+		defer u.Stop()
+
+		conn, err := net.Dial("udp4", u.Addr().String())
+		if err != nil {
+			t.Fatal("\tShould be able to dial a new UDP connection.", failed, err)
+		}
+		defer conn.Close()
+
+		conn.Write([]byte("hello"))
+
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		data := make([]byte, 20)
+		n, err := conn.Read(data)
+		if err != nil {
+			t.Fatal("\tShould be able to read the response from the connection.", failed, err)
+		}
+
+		if response := string(data[:n]); response != "GOT IT" {
+			t.Fatalf("\tShould receive the string \"GOT IT\" : %q %s", response, failed)
+		}
+		t.Log("\tShould receive the string \"GOT IT\".", success)
+	}
+}
+
+// TestUDPSender validates a handler can reply and send to other clients
+// while processing.
+func TestUDPSender(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	t.Log("Given the need to send to more than the requesting client.")
+	{
+		other, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			t.Fatal("\tShould be able to open a client socket.", failed, err)
+		}
+		defer other.Close()
+
+		cfg := udp.Config{
+			NetType: "udp4",
+			Addr:    "127.0.0.1:0",
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler: funcReqHandler{
+				process: func(r *udp.Request) {
+					var s udp.Sender = r
+					s.Reply([]byte("reply"))
+					s.SendTo(other.LocalAddr().(*net.UDPAddr), []byte("forward"))
+				},
+			},
+			RespHandler: udpRespHandler{},
+		}
+
+		u, err := udp.New("TEST", cfg)
+		if err != nil {
+			t.Fatal("\tShould be able to create a new UDP listener.", failed, err)
+		}
+
+		if err := u.Start(); err != nil {
+			t.Fatal("\tShould be able to start the UDP listener.", failed, err)
+		}
+		defer u.Stop()
+
+		conn, err := net.Dial("udp4", u.Addr().String())
+		if err != nil {
+			t.Fatal("\tShould be able to dial a new UDP connection.", failed, err)
+		}
+		defer conn.Close()
+
+		conn.Write([]byte("hello"))
+
+		read := func(c net.Conn) string {
+			c.SetReadDeadline(time.Now().Add(2 * time.Second))
+			data := make([]byte, 20)
+			n, _ := c.Read(data)
+			return string(data[:n])
+		}
+
+		if got := read(conn); got != "reply" {
+			t.Fatalf("\tShould reply to the client : %q %s", got, failed)
+		}
+		t.Log("\tShould reply to the client.", success)
+
+		if got := read(other); got != "forward" {
+			t.Fatalf("\tShould send to the other client : %q %s", got, failed)
+		}
+		t.Log("\tShould send to the other client.", success)
+	}
+}
+
+// TestUDPStartReady validates a datagram sent as soon as Start returns is
+// processed without waiting on the accept routine to initialize.
+func TestUDPStartReady(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	t.Log("Given the need to process datagrams as soon as Start returns.")
+	{
+		reqs := make(chan *udp.Request, 1)
+
+		cfg := udp.Config{
+			NetType: "udp4",
+			Addr:    "127.0.0.1:0",
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler: funcReqHandler{
+				process: func(r *udp.Request) {
+					reqs <- r
+				},
+			},
+			RespHandler: udpRespHandler{},
+
+			// Slow initialization must finish before Start returns.
+			NewWorkerState: func() interface{} {
+				time.Sleep(100 * time.Millisecond)
+				return nil
+			},
+		}
+
+		u, err := udp.New("TEST", cfg)
+		if err != nil {
+			t.Fatal("\tShould be able to create a new UDP listener.", failed, err)
+		}
+
+		if err := u.Start(); err != nil {
+			t.Fatal("\tShould be able to start the UDP listener.", failed, err)
+		}
+		defer u.Stop()
+
+		conn, err := net.Dial("udp4", u.Addr().String())
+		if err != nil {
+			t.Fatal("\tShould be able to dial a new UDP connection.", failed, err)
+		}
+		defer conn.Close()
+
+		sent := time.Now()
+		conn.Write([]byte("early"))
+
+		r := <-reqs
+		if latency := r.ReadAt.Sub(sent); latency > 50*time.Millisecond {
+			t.Fatalf("\tShould read the datagram without delay : %v %s", latency, failed)
+		}
+		t.Log("\tShould read the datagram without delay.", success)
+	}
+}
+
+// TestUDPOnDrop validates dropped datagrams are reported with their
+// details and the reports are limited.
+func TestUDPOnDrop(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	t.Log("Given the need to know why specific datagrams are dropped.")
+	{
+		drops := make(chan udp.DropInfo, 10)
+
+		cfg := udp.Config{
+			NetType: "udp4",
+			Addr:    "127.0.0.1:0",
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler:  udpReqHandler{},
+			RespHandler: udpRespHandler{},
+
+			MaxIPv4Size: 10,
+			OnDrop: func(di udp.DropInfo) {
+				drops <- di
+			},
+			OnDropLimit: 1,
+		}
+
+		u, err := udp.New("TEST", cfg)
+		if err != nil {
+			t.Fatal("\tShould be able to create a new UDP listener.", failed, err)
+		}
+
+		if err := u.Start(); err != nil {
+			t.Fatal("\tShould be able to start the UDP listener.", failed, err)
+		}
+
+		conn, err := net.Dial("udp4", u.Addr().String())
+		if err != nil {
+			t.Fatal("\tShould be able to dial a new UDP connection.", failed, err)
+		}
+		defer conn.Close()
+
+		conn.Write(make([]byte, 15))
+		conn.Write(make([]byte, 15))
+
+		di := <-drops
+		if di.Reason != udp.DropTooLarge || di.Length != 15 || di.UDPAddr.String() != conn.LocalAddr().String() {
+			t.Fatalf("\tShould report the dropped datagram : %+v %s", di, failed)
+		}
+		t.Log("\tShould report the dropped datagram.", success)
+
+		// Give the second datagram time to be read.
+		time.Sleep(50 * time.Millisecond)
+		u.Stop()
+
+		if len(drops) != 0 {
+			t.Fatal("\tShould limit the reports to one per second.", failed)
+		}
+		t.Log("\tShould limit the reports to one per second.", success)
+	}
+}
+
+// TestUDPStopDrain validates Stop waits for the request being processed
+// and gives up after the shutdown timeout.
+func TestUDPStopDrain(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		err     error
+	}{
+		{"without a timeout", 0, nil},
+		{"with a timeout", 50 * time.Millisecond, udp.ErrShutdownTimeout},
+	}
+
+	t.Log("Given the need to finish in-flight requests on Stop.")
+	{
+		for _, tt := range tests {
+			t.Logf("\tWhen stopping %s", tt.name)
+			{
+				var finished int32
+				started := make(chan struct{})
+
+				cfg := udp.Config{
+					NetType: "udp4",
+					Addr:    "127.0.0.1:0",
+
+					ConnHandler: udpConnHandler{},
+					ReqHandler: funcReqHandler{
+						process: func(r *udp.Request) {
+							close(started)
+							time.Sleep(300 * time.Millisecond)
+							atomic.StoreInt32(&finished, 1)
+						},
+					},
+					RespHandler: udpRespHandler{},
+
+					ShutdownTimeout: tt.timeout,
+				}
+
+				u, err := udp.New("TEST", cfg)
+				if err != nil {
+					t.Fatal("\t\tShould be able to create a new UDP listener.", failed, err)
+				}
+
+				if err := u.Start(); err != nil {
+					t.Fatal("\t\tShould be able to start the UDP listener.", failed, err)
+				}
+
+				conn, err := net.Dial("udp4", u.Addr().String())
+				if err != nil {
+					t.Fatal("\t\tShould be able to dial a new UDP connection.", failed, err)
+				}
+
+				conn.Write([]byte("slow"))
+				<-started
+
+				if err := u.Stop(); err != tt.err {
+					t.Fatalf("\t\tShould return %v from Stop : %v %s", tt.err, err, failed)
+				}
+				t.Logf("\t\tShould return %v from Stop. %s", tt.err, success)
+
+				done := atomic.LoadInt32(&finished) == 1
+				if done != (tt.err == nil) {
+					t.Fatalf("\t\tShould only return once the request finished : %v %s", done, failed)
+				}
+				t.Log("\t\tShould only return once the request finished, unless timed out.", success)
+
+				conn.Close()
+			}
+		}
+	}
+}
+
+// TestUDPStopAbandoned validates the listener is not started again while
+// a request abandoned by Stop is still running.
+func TestUDPStopAbandoned(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	t.Log("Given the need to restart a listener that gave up on a request.")
+	{
+		release := make(chan struct{})
+		processed := make(chan string, 2)
+
+		cfg := udp.Config{
+			NetType: "udp4",
+			Addr:    "127.0.0.1:0",
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler: funcReqHandler{
+				process: func(r *udp.Request) {
+					msg := string(r.Data[:r.Length])
+					if msg == "slow" {
+						<-release
+					}
+					processed <- msg
+				},
+			},
+			RespHandler: udpRespHandler{},
+
+			ShutdownTimeout: 50 * time.Millisecond,
+		}
+
+		u, err := udp.New("TEST", cfg)
+		if err != nil {
+			t.Fatal("\tShould be able to create a new UDP listener.", failed, err)
+		}
+
+		if err := u.Start(); err != nil {
+			t.Fatal("\tShould be able to start the UDP listener.", failed, err)
+		}
+
+		conn, err := net.Dial("udp4", u.Addr().String())
+		if err != nil {
+			t.Fatal("\tShould be able to dial a new UDP connection.", failed, err)
+		}
+		defer conn.Close()
+
+		conn.Write([]byte("slow"))
+
+		for deadline := time.Now().Add(time.Second); u.Stats().InFlight != 1; {
+			if time.Now().After(deadline) {
+				t.Fatalf("\tShould process the request : %+v %s", u.Stats(), failed)
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		if err := u.Stop(); err != udp.ErrShutdownTimeout {
+			t.Fatalf("\tShould give up on the request : %v %s", err, failed)
+		}
+
+		if err := u.Start(); err != udp.ErrStillStopping {
+			t.Fatalf("\tShould refuse to start while the request is running : %v %s", err, failed)
+		}
+		t.Log("\tShould refuse to start while the request is running.", success)
+
+		close(release)
+		if msg := <-processed; msg != "slow" {
+			t.Fatalf("\tShould finish the request abandoned : %s %s", msg, failed)
+		}
+
+		// The routine returning the request has yet to exit.
+		for deadline := time.Now().Add(time.Second); ; {
+			err := u.Start()
+			if err == nil {
+				break
+			}
+			if err != udp.ErrStillStopping || time.Now().After(deadline) {
+				t.Fatalf("\tShould start once the request has returned : %v %s", err, failed)
+			}
+			time.Sleep(time.Millisecond)
+		}
+		defer u.Stop()
+		t.Log("\tShould start once the request has returned.", success)
+
+		conn, err = net.Dial("udp4", u.Addr().String())
+		if err != nil {
+			t.Fatal("\tShould be able to dial a new UDP connection.", failed, err)
+		}
+		defer conn.Close()
+
+		conn.Write([]byte("fast"))
+		select {
+		case msg := <-processed:
+			if msg != "fast" {
+				t.Fatalf("\tShould process requests after the restart : %s %s", msg, failed)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("\tShould process requests after the restart.", failed)
+		}
+		t.Log("\tShould process requests after the restart.", success)
+	}
+}
+
+// TestUDPStopWithContext validates the requests already read, including
+// queued ones, get to respond before the socket is closed.
+func TestUDPStopWithContext(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	const queued = 3
+
+	t.Log("Given the need to drain the listener before shutting it down.")
+	{
+		release := make(chan struct{})
+
+		cfg := udp.Config{
+			NetType: "udp4",
+			Addr:    "127.0.0.1:0",
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler: funcReqHandler{
+				process: func(r *udp.Request) {
+					select {
+					case <-release:
+					case <-r.Context().Done():
+						return
+					}
+					r.Reply([]byte("GOT IT"))
+				},
+			},
+			RespHandler: udpRespHandler{},
+
+			MaxWorkers: 1,
+			QueueDepth: queued,
+		}
+
+		u, err := udp.New("TEST", cfg)
+		if err != nil {
+			t.Fatal("\tShould be able to create a new UDP listener.", failed, err)
+		}
+
+		if err := u.Start(); err != nil {
+			t.Fatal("\tShould be able to start the UDP listener.", failed, err)
+		}
+
+		conn, err := net.Dial("udp4", u.Addr().String())
+		if err != nil {
+			t.Fatal("\tShould be able to dial a new UDP connection.", failed, err)
+		}
+		defer conn.Close()
+
+		for i := 0; i < queued; i++ {
+			conn.Write(make([]byte, 20))
+		}
+
+		for deadline := time.Now().Add(time.Second); u.Stats().Recv < queued; {
+			if time.Now().After(deadline) {
+				t.Fatalf("\tShould read the requests : %+v %s", u.Stats(), failed)
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		// Let the requests finish only once the drain has begun.
+		ch := u.Subscribe()
+		draining := make(chan udp.State, 1)
+		go func() {
+			draining <- <-ch
+			close(release)
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		if err := u.StopWithContext(ctx); err != nil {
+			t.Fatal("\tShould drain the listener.", failed, err)
+		}
+		t.Log("\tShould drain the listener.", success)
+
+		if s := <-draining; s != udp.StateDraining {
+			t.Fatalf("\tShould be in the draining state during the drain : %v %s", s, failed)
+		}
+		t.Log("\tShould be in the draining state during the drain.", success)
+
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		for i := 0; i < queued; i++ {
+			if _, err := conn.Read(make([]byte, 6)); err != nil {
+				t.Fatalf("\tShould receive the response to every request : %d %v %s", i, err, failed)
+			}
+		}
+		t.Log("\tShould receive the response to every request.", success)
+	}
+
+	t.Log("Given the need to bound the drain.")
+	{
+		cfg := udp.Config{
+			NetType: "udp4",
+			Addr:    "127.0.0.1:0",
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler: funcReqHandler{
+				process: func(r *udp.Request) {
+					<-r.Context().Done()
+				},
+			},
+			RespHandler: udpRespHandler{},
+		}
+
+		u, err := udp.New("TEST", cfg)
+		if err != nil {
+			t.Fatal("\tShould be able to create a new UDP listener.", failed, err)
+		}
+
+		if err := u.Start(); err != nil {
+			t.Fatal("\tShould be able to start the UDP listener.", failed, err)
+		}
+
+		conn, err := net.Dial("udp4", u.Addr().String())
+		if err != nil {
+			t.Fatal("\tShould be able to dial a new UDP connection.", failed, err)
+		}
+		defer conn.Close()
+
+		conn.Write(make([]byte, 20))
+
+		for deadline := time.Now().Add(time.Second); u.Stats().InFlight == 0; {
+			if time.Now().After(deadline) {
+				t.Fatalf("\tShould process the request : %+v %s", u.Stats(), failed)
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		if err := u.StopWithContext(ctx); err != udp.ErrShutdownTimeout {
+			t.Fatalf("\tShould give up once the context is done : %v %s", err, failed)
+		}
+		t.Log("\tShould give up once the context is done.", success)
+
+		if u.State() != udp.StateStopped {
+			t.Fatal("\tShould be stopped.", failed)
+		}
+		t.Log("\tShould be stopped.", success)
+	}
+}
+
+// TestUDPEventReadError validates read errors are reported through the
+// event handler so they can be routed to any logger.
+func TestUDPEventReadError(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	t.Log("Given the need to capture the diagnostics of a read error.")
+	{
+		var mu sync.Mutex
+		var log bytes.Buffer
+
+		var fails int32
+
+		cfg := udp.Config{
+			NetType: "udp4",
+			Addr:    "127.0.0.1:0",
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler:  failingReqHandler{failed: &fails},
+			RespHandler: udpRespHandler{},
+
+			OptEvent: udp.OptEvent{
+				Event: func(event string, format string, a ...interface{}) {
+					mu.Lock()
+					defer mu.Unlock()
+					fmt.Fprintf(&log, "%s : "+format+"\n", append([]interface{}{event}, a...)...)
+				},
+			},
+		}
+
+		u, err := udp.New("TEST", cfg)
+		if err != nil {
+			t.Fatal("\tShould be able to create a new UDP listener.", failed, err)
+		}
+
+		if err := u.Start(); err != nil {
+			t.Fatal("\tShould be able to start the UDP listener.", failed, err)
+		}
+
+		// The first read fails right away, the second waits for data.
+		time.Sleep(50 * time.Millisecond)
+		u.Stop()
+
+		mu.Lock()
+		out := log.String()
+		mu.Unlock()
+
+		if !strings.Contains(out, "accept : ERROR : temporary read failure") {
+			t.Fatalf("\tShould report the read error : %q %s", out, failed)
+		}
+		t.Log("\tShould report the read error.", success)
+	}
+}
+
+// TestUDPStats validates the counters track the datagrams received,
+// processed and written.
+func TestUDPStats(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	const n = 5
+
+	t.Log("Given the need to count the work of the listener.")
+	{
+		cfg := udp.Config{
+			NetType: "udp4",
+			Addr:    "127.0.0.1:0",
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler:  udpReqHandler{},
+			RespHandler: udpRespHandler{},
+		}
+
+		u, err := udp.New("TEST", cfg)
+		if err != nil {
+			t.Fatal("\tShould be able to create a new UDP listener.", failed, err)
+		}
+
+		if err := u.Start(); err != nil {
+			t.Fatal("\tShould be able to start the UDP listener.", failed, err)
+		}
+
+		conn, err := net.Dial("udp4", u.Addr().String())
+		if err != nil {
+			t.Fatal("\tShould be able to dial a new UDP connection.", failed, err)
+		}
+		defer conn.Close()
+
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		for i := 0; i < n; i++ {
+			conn.Write(make([]byte, 20))
+
+			data := make([]byte, 6)
+			if _, err := conn.Read(data); err != nil {
+				t.Fatal("\tShould be able to read the response from the connection.", failed, err)
+			}
+		}
+
+		// Stopping waits for the last request to be counted.
+		u.Stop()
+
+		sd := u.Stats()
+		if sd.Recv != n || sd.Process != n || sd.Writes != n {
+			t.Fatalf("\tShould count %d datagrams received, processed and written : %+v %s", n, sd, failed)
+		}
+		t.Logf("\tShould count %d datagrams received, processed and written. %s", n, success)
+
+		if sd.RecvBytes != n*20 || sd.WriteBytes != n*6 || sd.ProcessTime <= 0 {
+			t.Fatalf("\tShould count the bytes and the processing time : %+v %s", sd, failed)
+		}
+		t.Log("\tShould count the bytes and the processing time.", success)
+
+		name := fmt.Sprintf("udp_test_stats_%p", u)
+		u.Publish(name)
+
+		var vars struct{ Recv int64 }
+		if err := json.Unmarshal([]byte(expvar.Get(name).String()), &vars); err != nil || vars.Recv != n {
+			t.Fatalf("\tShould publish the counters with expvar : %+v %v %s", vars, err, failed)
+		}
+		t.Log("\tShould publish the counters with expvar.", success)
+
+		if sd.InFlight != 0 || sd.ReadErrors != 0 || sd.Drops != 0 {
+			t.Fatalf("\tShould have nothing in flight, failed or dropped : %+v %s", sd, failed)
+		}
+		t.Log("\tShould have nothing in flight, failed or dropped.", success)
+	}
+
+	t.Log("Given the need to read the counters while the listener starts.")
+	{
+		cfg := udp.Config{
+			NetType: "udp4",
+			Addr:    "127.0.0.1:0",
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler:  udpReqHandler{},
+			RespHandler: udpRespHandler{},
+
+			MaxWorkers: 2,
+			QueueDepth: 4,
+		}
+
+		u, err := udp.New("TEST", cfg)
+		if err != nil {
+			t.Fatal("\tShould be able to create a new UDP listener.", failed, err)
+		}
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for u.State() != udp.StateStarted {
+				u.Stats()
+			}
+		}()
+
+		if err := u.Start(); err != nil {
+			t.Fatal("\tShould be able to start the UDP listener.", failed, err)
+		}
+		<-done
+		u.Stop()
+
+		t.Log("\tShould be able to read the counters while starting.", success)
+	}
+}
+
+// TestUDPHooks validates the lifecycle and error callbacks are called.
+func TestUDPHooks(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	t.Log("Given the need to observe the listener's lifecycle and errors.")
+	{
+		var started net.Addr
+		var errs []error
+		stopped := make(chan error, 1)
+
+		cfg := udp.Config{
+			NetType: "udp4",
+			Addr:    "127.0.0.1:0",
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler:  udpReqHandler{},
+			RespHandler: udpRespHandler{},
+
+			OnStart: func(addr net.Addr) { started = addr },
+			OnStop:  func(err error) { stopped <- err },
+			OnError: func(err error) { errs = append(errs, err) },
+		}
+
+		u, err := udp.New("TEST", cfg)
+		if err != nil {
+			t.Fatal("\tShould be able to create a new UDP listener.", failed, err)
+		}
+
+		if err := u.Start(); err != nil {
+			t.Fatal("\tShould be able to start the UDP listener.", failed, err)
+		}
+
+		if started == nil || started.String() != u.Addr().String() {
+			t.Fatalf("\tShould report the address on start : %v %s", started, failed)
+		}
+		t.Log("\tShould report the address on start.", success)
+
+		// A response without a destination can't be written.
+		resp := udp.Response{Data: []byte("GOT IT"), Length: 6}
+		if err := u.Send(&resp); err == nil || len(errs) != 1 || errs[0] != err {
+			t.Fatalf("\tShould report the failed write : %v %v %s", err, errs, failed)
+		}
+		t.Log("\tShould report the failed write.", success)
+
+		u.Stop()
+
+		select {
+		case err := <-stopped:
+			if err != nil {
+				t.Fatalf("\tShould report a clean stop : %v %s", err, failed)
+			}
+			t.Log("\tShould report a clean stop.", success)
+		default:
+			t.Fatal("\tShould report a clean stop.", failed)
+		}
+	}
+}
+
+// TestUDPTruncated validates datagrams filling the read buffer are flagged
+// as possibly truncated.
+func TestUDPTruncated(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	reqs := make(chan udp.Request, 1)
+	read := funcReqHandler{
+		process: func(r *udp.Request) {
+			reqs <- *r
+		},
+	}
+
+	handlers := []struct {
+		name string
+		h    udp.ReqHandler
+	}{
+		{"returns the whole buffer", read},
+		{"returns the buffer sliced to the length", slicedReqHandler{read}},
+	}
+
+	tests := []struct {
+		size      int
+		length    int
+		truncated bool
+	}{
+		{5, 5, false},
+		{19, 19, false},
+		{20, 20, true},
+		{21, 20, true},
+	}
+
+	t.Log("Given the need to detect datagrams larger than the read buffer.")
+	{
+		for _, hh := range handlers {
+			t.Logf("\tWhen Read %s", hh.name)
+			{
+				cfg := udp.Config{
+					NetType: "udp4",
+					Addr:    "127.0.0.1:0",
+
+					ConnHandler: udpConnHandler{},
+					ReqHandler:  hh.h,
+					RespHandler: udpRespHandler{},
+
+					ReadBufferSize:  1 << 20,
+					WriteBufferSize: 1 << 20,
+				}
+
+				u, err := udp.New("TEST", cfg)
+				if err != nil {
+					t.Fatal("\t\tShould be able to create a new UDP listener.", failed, err)
+				}
+
+				if err := u.Start(); err != nil {
+					t.Fatal("\t\tShould be able to start the UDP listener.", failed, err)
+				}
+				t.Log("\t\tShould be able to size the socket buffers.", success)
+				defer u.Stop()
+
+				conn, err := net.Dial("udp4", u.Addr().String())
+				if err != nil {
+					t.Fatal("\t\tShould be able to dial a new UDP connection.", failed, err)
+				}
+				defer conn.Close()
+
+				for _, tt := range tests {
+					conn.Write(make([]byte, tt.size))
+
+					r := <-reqs
+					if r.Length != tt.length || r.Truncated != tt.truncated {
+						t.Errorf("\t\tShould flag a %d byte datagram truncated %v : %d %v %s", tt.size, tt.truncated, r.Length, r.Truncated, failed)
+						continue
+					}
+					t.Logf("\t\tShould flag a %d byte datagram truncated %v. %s", tt.size, tt.truncated, success)
+				}
+			}
+		}
+	}
+}
+
+// TestUDPBufferReqHandler validates datagrams are read into the buffers
+// pooled by the package.
+func TestUDPBufferReqHandler(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	const size = 32
+
+	tests := []struct {
+		data      string
+		truncated bool
+	}{
+		{"pooled buffer", false},
+		{strings.Repeat("x", size+1), true},
+	}
+
+	t.Log("Given the need to read datagrams without allocating buffers.")
+	{
+		reqs := make(chan udp.Request, 1)
+		data := make(chan string, 1)
+
+		cfg := udp.Config{
+			NetType: "udp4",
+			Addr:    "127.0.0.1:0",
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler: bufferReqHandler{
+				funcReqHandler{
+					process: func(r *udp.Request) {
+
+						// The buffer goes back to the pool, so copy what
+						// is being kept.
+						data <- string(r.Data[:r.Length])
+						reqs <- *r
+					},
+				},
+			},
+			RespHandler: udpRespHandler{},
+
+			MaxPacketSize: size,
+		}
+
+		u, err := udp.New("TEST", cfg)
+		if err != nil {
+			t.Fatal("\tShould be able to create a new UDP listener.", failed, err)
+		}
+
+		if err := u.Start(); err != nil {
+			t.Fatal("\tShould be able to start the UDP listener.", failed, err)
+		}
+		defer u.Stop()
+
+		conn, err := net.Dial("udp4", u.Addr().String())
+		if err != nil {
+			t.Fatal("\tShould be able to dial a new UDP connection.", failed, err)
+		}
+		defer conn.Close()
+
+		for _, tt := range tests {
+			conn.Write([]byte(tt.data))
+
+			got, r := <-data, <-reqs
+			if len(r.Data) != size || r.Truncated != tt.truncated || got != tt.data[:r.Length] {
+				t.Errorf("\tShould read a %d byte datagram into a %d byte buffer : %d %v %s", len(tt.data), size, len(r.Data), r.Truncated, failed)
+				continue
+			}
+			t.Logf("\tShould read a %d byte datagram into a %d byte buffer. %s", len(tt.data), size, success)
+		}
+	}
+}
+
+// TestUDPBufferReqHandlerTimeout validates pooled requests can carry a
+// request timeout, whether processed by workers or not.
+func TestUDPBufferReqHandlerTimeout(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	const sent = 10
+
+	t.Log("Given the need to bound the time of requests read into pooled buffers.")
+	{
+		for _, workers := range []int{0, 2} {
+			t.Logf("\tWhen there are %d workers", workers)
+			{
+				results := make(chan bool, sent)
+
+				cfg := udp.Config{
+					NetType: "udp4",
+					Addr:    "127.0.0.1:0",
+
+					ConnHandler: udpConnHandler{},
+					ReqHandler: bufferReqHandler{
+						funcReqHandler{
+							process: func(r *udp.Request) {
+								ctx, cancel := context.WithCancel(r.Context())
+								defer cancel()
+
+								_, ok := ctx.Deadline()
+								got, _ := udp.FromContext(ctx)
+								results <- ok && got == r
+							},
+						},
+					},
+					RespHandler: udpRespHandler{},
+
+					RequestTimeout: time.Second,
+					MaxWorkers:     workers,
+					QueuePolicy:    udp.QueueBlock,
+				}
+
+				u, err := udp.New("TEST", cfg)
+				if err != nil {
+					t.Fatal("\t\tShould be able to create a new UDP listener.", failed, err)
+				}
+
+				if err := u.Start(); err != nil {
+					t.Fatal("\t\tShould be able to start the UDP listener.", failed, err)
+				}
+
+				conn, err := net.Dial("udp4", u.Addr().String())
+				if err != nil {
+					t.Fatal("\t\tShould be able to dial a new UDP connection.", failed, err)
+				}
+
+				for i := 0; i < sent; i++ {
+					conn.Write([]byte("work"))
+				}
+
+				for i := 0; i < sent; i++ {
+					select {
+					case ok := <-results:
+						if !ok {
+							t.Fatalf("\t\tShould give each request its deadline and itself : %d %s", i, failed)
+						}
+					case <-time.After(time.Second):
+						t.Fatalf("\t\tShould process every request : %d %s", i, failed)
+					}
+				}
+				t.Log("\t\tShould process every request with its deadline.", success)
+
+				conn.Close()
+				u.Stop()
+			}
+		}
+	}
+}
+
+// TestUDPPush validates data can be sent to a known address without an
+// incoming request.
+func TestUDPPush(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	t.Log("Given the need to push data to a client without a request.")
+	{
+		client, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			t.Fatal("\tShould be able to open a client socket.", failed, err)
+		}
+		defer client.Close()
+
+		resp := udp.Response{
+			UDPAddr: client.LocalAddr().(*net.UDPAddr),
+			Data:    []byte("push"),
+			Length:  4,
+		}
+
+		u := newTestUDP(t)
+
+		if err := u.Send(&resp); err != udp.ErrNotStarted {
+			t.Fatalf("\tShould not be able to send before Start : %v %s", err, failed)
+		}
+		t.Log("\tShould not be able to send before Start.", success)
+
+		if err := u.Start(); err != nil {
+			t.Fatal("\tShould be able to start the UDP listener.", failed, err)
+		}
+		defer u.Stop()
+
+		if err := u.Send(&resp); err != nil {
+			t.Fatal("\tShould be able to send after Start.", failed, err)
+		}
+
+		client.SetReadDeadline(time.Now().Add(2 * time.Second))
+		data := make([]byte, 20)
+		n, err := client.Read(data)
+		if err != nil || string(data[:n]) != "push" {
+			t.Fatalf("\tShould receive the pushed data : %q %v %s", data[:n], err, failed)
+		}
+		t.Log("\tShould receive the pushed data.", success)
+
+		if w := u.Stats().Writes; w != 1 {
+			t.Fatalf("\tShould count the write : %d %s", w, failed)
+		}
+		t.Log("\tShould count the write.", success)
+	}
+}
+
+// TestUDPWorkers validates a flood of datagrams is processed by a bounded
+// number of workers and the excess is dropped.
+func TestUDPWorkers(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	const (
+		workers = 2
+		depth   = 4
+		flood   = 50
+	)
+
+	t.Log("Given the need to bound the routines processing a flood of datagrams.")
+	{
+		var current, max int32
+		release := make(chan struct{})
+
+		cfg := udp.Config{
+			NetType: "udp4",
+			Addr:    "127.0.0.1:0",
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler: funcReqHandler{
+				process: func(r *udp.Request) {
+					n := atomic.AddInt32(&current, 1)
+					for {
+						m := atomic.LoadInt32(&max)
+						if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+							break
+						}
+					}
+
+					<-release
+					atomic.AddInt32(&current, -1)
+				},
+			},
+			RespHandler: udpRespHandler{},
+
+			MaxWorkers: workers,
+			QueueDepth: depth,
+		}
+
+		u, err := udp.New("TEST", cfg)
+		if err != nil {
+			t.Fatal("\tShould be able to create a new UDP listener.", failed, err)
+		}
+
+		base := runtime.NumGoroutine()
+
+		if err := u.Start(); err != nil {
+			t.Fatal("\tShould be able to start the UDP listener.", failed, err)
+		}
+
+		conn, err := net.Dial("udp4", u.Addr().String())
+		if err != nil {
+			t.Fatal("\tShould be able to dial a new UDP connection.", failed, err)
+		}
+		defer conn.Close()
+
+		for i := 0; i < flood; i++ {
+			conn.Write(make([]byte, 20))
+		}
+
+		for deadline := time.Now().Add(2 * time.Second); u.Stats().Recv < flood; {
+			if time.Now().After(deadline) {
+				t.Fatalf("\tShould read the flood : %+v %s", u.Stats(), failed)
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		if n := runtime.NumGoroutine() - base; n > workers+1 {
+			t.Errorf("\tShould run at most %d routines : %d %s", workers+1, n, failed)
+		} else {
+			t.Logf("\tShould run at most %d routines. %s", workers+1, success)
+		}
+
+		close(release)
+		u.Stop()
+
+		sd := u.Stats()
+		if atomic.LoadInt32(&max) > workers || sd.Process > workers+depth {
+			t.Errorf("\tShould process at most %d at a time and %d in all : %d %+v %s", workers, workers+depth, max, sd, failed)
+		} else {
+			t.Logf("\tShould process at most %d at a time and %d in all. %s", workers, workers+depth, success)
+		}
+
+		if sd.Process+sd.Drops != flood {
+			t.Errorf("\tShould drop what could not be queued : %+v %s", sd, failed)
+		} else {
+			t.Log("\tShould drop what could not be queued.", success)
+		}
+	}
+}
+
+// TestUDPQueuePolicy validates the requests kept when the worker queue
+// overflows.
+func TestUDPQueuePolicy(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	tests := []struct {
+		policy    udp.QueuePolicy
+		processed []byte
+	}{
+		{udp.QueueDropNew, []byte{0, 1, 2}},
+		{udp.QueueDropOldest, []byte{0, 3, 4}},
+		{udp.QueueBlock, []byte{0, 1, 2, 3, 4}},
+	}
+
+	t.Log("Given the need to choose which requests survive a full queue.")
+	{
+		for _, tt := range tests {
+			t.Logf("\tWhen the policy is %v", tt.policy)
+			{
+				var mu sync.Mutex
+				var processed []byte
+				release := make(chan struct{})
+
+				cfg := udp.Config{
+					NetType: "udp4",
+					Addr:    "127.0.0.1:0",
+
+					ConnHandler: udpConnHandler{},
+					ReqHandler: funcReqHandler{
+						process: func(r *udp.Request) {
+							<-release
+
+							mu.Lock()
+							processed = append(processed, r.Data[0])
+							mu.Unlock()
+						},
+					},
+					RespHandler: udpRespHandler{},
+
+					MaxWorkers:  1,
+					QueueDepth:  2,
+					QueuePolicy: tt.policy,
+				}
+
+				u, err := udp.New("TEST", cfg)
+				if err != nil {
+					t.Fatal("\t\tShould be able to create a new UDP listener.", failed, err)
+				}
+
+				if err := u.Start(); err != nil {
+					t.Fatal("\t\tShould be able to start the UDP listener.", failed, err)
+				}
+
+				conn, err := net.Dial("udp4", u.Addr().String())
+				if err != nil {
+					t.Fatal("\t\tShould be able to dial a new UDP connection.", failed, err)
+				}
+
+				// Keep the worker busy with the first request, then overflow
+				// the queue behind it.
+				for i := 0; i < 5; i++ {
+					data := make([]byte, 20)
+					data[0] = byte(i)
+					conn.Write(data)
+
+					for deadline := time.Now().Add(time.Second); i == 0 && u.Stats().InFlight == 0; {
+						if time.Now().After(deadline) {
+							t.Fatal("\t\tShould process the first request.", failed)
+						}
+						time.Sleep(time.Millisecond)
+					}
+				}
+				conn.Close()
+
+				for deadline := time.Now().Add(time.Second); u.Stats().Recv < 4; {
+					if time.Now().After(deadline) {
+						t.Fatalf("\t\tShould read the requests : %+v %s", u.Stats(), failed)
+					}
+					time.Sleep(time.Millisecond)
+				}
+				time.Sleep(10 * time.Millisecond)
+
+				close(release)
+				for deadline := time.Now().Add(time.Second); u.Stats().Process < int64(len(tt.processed)); {
+					if time.Now().After(deadline) {
+						break
+					}
+					time.Sleep(time.Millisecond)
+				}
+				u.Stop()
+
+				mu.Lock()
+				got := string(processed)
+				mu.Unlock()
+
+				if got != string(tt.processed) {
+					t.Errorf("\t\tShould process requests %v : %v %s", tt.processed, []byte(got), failed)
+				} else {
+					t.Logf("\t\tShould process requests %v. %s", tt.processed, success)
+				}
+
+				if drops := u.Stats().QueueDrops; drops != int64(5-len(tt.processed)) {
+					t.Errorf("\t\tShould count %d queue drops : %d %s", 5-len(tt.processed), drops, failed)
+				} else {
+					t.Logf("\t\tShould count %d queue drops. %s", 5-len(tt.processed), success)
+				}
+			}
+		}
+	}
+}
+
+// TestUDPQueueDepthAtEnqueue validates a request reports the requests
+// waiting for a worker when it was queued.
+func TestUDPQueueDepthAtEnqueue(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	const sent = 4
+
+	t.Log("Given the need to know how busy the listener is from a handler.")
+	{
+		release := make(chan struct{})
+		depths := make(chan int, sent)
+
+		cfg := udp.Config{
+			NetType: "udp4",
+			Addr:    "127.0.0.1:0",
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler: funcReqHandler{
+				process: func(r *udp.Request) {
+					<-release
+					depths <- r.QueueDepthAtEnqueue
+				},
+			},
+			RespHandler: udpRespHandler{},
+
+			MaxWorkers: 1,
+			QueueDepth: sent,
+		}
+
+		u, err := udp.New("TEST", cfg)
+		if err != nil {
+			t.Fatal("\tShould be able to create a new UDP listener.", failed, err)
+		}
+
+		if err := u.Start(); err != nil {
+			t.Fatal("\tShould be able to start the UDP listener.", failed, err)
+		}
+		defer u.Stop()
+
+		conn, err := net.Dial("udp4", u.Addr().String())
+		if err != nil {
+			t.Fatal("\tShould be able to dial a new UDP connection.", failed, err)
+		}
+		defer conn.Close()
+
+		// The worker holds the first request, so each of the others is
+		// queued behind the ones sent before it.
+		send := func(ready func(sd udp.StatData) bool) {
+			conn.Write(make([]byte, 20))
+
+			for deadline := time.Now().Add(time.Second); !ready(u.Stats()); {
+				if time.Now().After(deadline) {
+					t.Fatalf("\tShould queue the request : %+v %s", u.Stats(), failed)
+				}
+				time.Sleep(time.Millisecond)
+			}
+		}
+
+		send(func(sd udp.StatData) bool { return sd.InFlight == 1 })
+		for i := 1; i < sent; i++ {
+			n := int64(i)
+			send(func(sd udp.StatData) bool { return sd.QueueLen == n })
+		}
+
+		close(release)
+
+		for i, want := range []int{0, 0, 1, 2} {
+			if got := <-depths; got != want {
+				t.Fatalf("\tShould report the queue depth of request %d as %d : %d %s", i, want, got, failed)
+			}
+		}
+		t.Log("\tShould report the queue depth at the time each request was queued.", success)
+	}
+}
+
+// TestUDPRateLimit validates a client sending faster than its rate has
+// the excess dropped.
+func TestUDPRateLimit(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	const (
+		rate  = 10
+		burst = 5
+		blast = 100
+	)
+
+	t.Log("Given the need to limit the rate of a single client.")
+	{
+		cfg := udp.Config{
+			NetType: "udp4",
+			Addr:    "127.0.0.1:0",
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler: funcReqHandler{
+				process: func(r *udp.Request) {},
+			},
+			RespHandler: udpRespHandler{},
+
+			RateLimit: &udp.RateLimitConfig{
+				PerIPRate: rate,
+				Burst:     burst,
+			},
+		}
+
+		u, err := udp.New("TEST", cfg)
+		if err != nil {
+			t.Fatal("\tShould be able to create a new UDP listener.", failed, err)
+		}
+
+		if err := u.Start(); err != nil {
+			t.Fatal("\tShould be able to start the UDP listener.", failed, err)
+		}
+
+		conn, err := net.Dial("udp4", u.Addr().String())
+		if err != nil {
+			t.Fatal("\tShould be able to dial a new UDP connection.", failed, err)
+		}
+		defer conn.Close()
+
+		start := time.Now()
+		for i := 0; i < blast; i++ {
+			conn.Write(make([]byte, 20))
+		}
+
+		for deadline := time.Now().Add(2 * time.Second); u.Stats().Recv < blast; {
+			if time.Now().After(deadline) {
+				t.Fatalf("\tShould read the blast : %+v %s", u.Stats(), failed)
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		elapsed := time.Since(start)
+		u.Stop()
+
+		sd := u.Stats()
+		allowed := burst + int64(rate*elapsed.Seconds()) + 1
+		if sd.Process < burst || sd.Process > allowed {
+			t.Fatalf("\tShould process between %d and %d datagrams : %+v %s", burst, allowed, sd, failed)
+		}
+		t.Logf("\tShould process between %d and %d datagrams. %s", burst, allowed, success)
+
+		if sd.Process+sd.Drops != blast {
+			t.Fatalf("\tShould drop the rest : %+v %s", sd, failed)
+		}
+		t.Log("\tShould drop the rest.", success)
+	}
+}
+
+// TestUDPPacketRate validates the datagrams of all clients together are
+// held under the packet rate cap.
+func TestUDPPacketRate(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	const (
+		rate  = 10
+		burst = 5
+		blast = 50
+	)
+
+	t.Log("Given the need to limit the packet rate of every client together.")
+	{
+		var reasons int32
+
+		cfg := udp.Config{
+			NetType: "udp4",
+			Addr:    "127.0.0.1:0",
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler: funcReqHandler{
+				process: func(r *udp.Request) {},
+			},
+			RespHandler: udpRespHandler{},
+
+			InboundPacketLimit: rate,
+			InboundPacketBurst: burst,
+
+			OnDrop: func(di udp.DropInfo) {
+				if di.Reason == udp.DropPacketRate {
+					atomic.AddInt32(&reasons, 1)
+				}
+			},
+		}
+
+		u, err := udp.New("TEST", cfg)
+		if err != nil {
+			t.Fatal("\tShould be able to create a new UDP listener.", failed, err)
+		}
+
+		if err := u.Start(); err != nil {
+			t.Fatal("\tShould be able to start the UDP listener.", failed, err)
+		}
+
+		start := time.Now()
+		for c := 0; c < 2; c++ {
+			conn, err := net.Dial("udp4", u.Addr().String())
+			if err != nil {
+				t.Fatal("\tShould be able to dial a new UDP connection.", failed, err)
+			}
+
+			for i := 0; i < blast; i++ {
+				conn.Write(make([]byte, 20))
+			}
+			conn.Close()
+		}
+
+		for deadline := time.Now().Add(2 * time.Second); u.Stats().Recv < 2*blast; {
+			if time.Now().After(deadline) {
+				t.Fatalf("\tShould read the blast : %+v %s", u.Stats(), failed)
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		elapsed := time.Since(start)
+		u.Stop()
+
+		sd := u.Stats()
+		allowed := burst + int64(rate*elapsed.Seconds()) + 1
+		if sd.Process < burst || sd.Process > allowed {
+			t.Fatalf("\tShould process between %d and %d datagrams : %+v %s", burst, allowed, sd, failed)
+		}
+		t.Logf("\tShould process between %d and %d datagrams. %s", burst, allowed, success)
+
+		if sd.Process+sd.Drops != 2*blast || atomic.LoadInt32(&reasons) == 0 {
+			t.Fatalf("\tShould drop the rest for the packet rate : %+v %s", sd, failed)
+		}
+		t.Log("\tShould drop the rest for the packet rate.", success)
+	}
+}
+
+// TestUDPRateLimitSources validates the least recently seen client is
+// forgotten when too many are tracked.
+func TestUDPRateLimitSources(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("binding 127.0.0.2 is only assured on linux")
+	}
+
+	resetLog()
+	defer displayLog()
+
+	t.Log("Given the need to bound the clients tracked by the rate limit.")
+	{
+		cfg := udp.Config{
+			NetType: "udp4",
+			Addr:    "127.0.0.1:0",
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler: funcReqHandler{
+				process: func(r *udp.Request) {},
+			},
+			RespHandler: udpRespHandler{},
+
+			RateLimit: &udp.RateLimitConfig{
+				PerIPRate:  0.001,
+				MaxSources: 1,
+			},
+		}
+
+		u, err := udp.New("TEST", cfg)
+		if err != nil {
+			t.Fatal("\tShould be able to create a new UDP listener.", failed, err)
+		}
+
+		if err := u.Start(); err != nil {
+			t.Fatal("\tShould be able to start the UDP listener.", failed, err)
+		}
+
+		// Each client gets a single datagram, so the second datagram from
+		// the first client is only allowed if its bucket was forgotten.
+		send := func(ip string, want int64) {
+			conn, err := net.DialUDP("udp4", &net.UDPAddr{IP: net.ParseIP(ip)}, u.Addr().(*net.UDPAddr))
+			if err != nil {
+				t.Fatal("\tShould be able to dial a new UDP connection.", failed, err)
+			}
+			defer conn.Close()
+
+			conn.Write(make([]byte, 20))
+
+			for deadline := time.Now().Add(time.Second); u.Stats().Process+u.Stats().Drops < want; {
+				if time.Now().After(deadline) {
+					t.Fatalf("\tShould read the datagram from %s : %+v %s", ip, u.Stats(), failed)
+				}
+				time.Sleep(time.Millisecond)
+			}
+		}
+
+		send("127.0.0.1", 1)
+		send("127.0.0.1", 2)
+		send("127.0.0.2", 3)
+		send("127.0.0.1", 4)
+
+		u.Stop()
+
+		if sd := u.Stats(); sd.Process != 3 || sd.Drops != 1 {
+			t.Fatalf("\tShould allow a client again once it is forgotten : %+v %s", sd, failed)
+		}
+		t.Log("\tShould allow a client again once it is forgotten.", success)
+	}
+}
+
+// TestUDPRateLimitNewSources validates the first datagrams of new clients
+// are limited without limiting the clients already tracked.
+func TestUDPRateLimitNewSources(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("binding 127.0.0.2 is only assured on linux")
+	}
+
+	resetLog()
+	defer displayLog()
+
+	t.Log("Given the need to throttle the clients appearing at once.")
+	{
+		var drops []udp.DropReason
+		var mu sync.Mutex
+
+		cfg := udp.Config{
+			NetType: "udp4",
+			Addr:    "127.0.0.1:0",
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler: funcReqHandler{
+				process: func(r *udp.Request) {},
+			},
+			RespHandler: udpRespHandler{},
+
+			RateLimit: &udp.RateLimitConfig{
+				PerIPRate:     1000,
+				NewSourceRate: 0.001,
+			},
+
+			OnDrop: func(di udp.DropInfo) {
+				mu.Lock()
+				drops = append(drops, di.Reason)
+				mu.Unlock()
+			},
+		}
+
+		u, err := udp.New("TEST", cfg)
+		if err != nil {
+			t.Fatal("\tShould be able to create a new UDP listener.", failed, err)
+		}
+
+		if err := u.Start(); err != nil {
+			t.Fatal("\tShould be able to start the UDP listener.", failed, err)
+		}
+
+		// A single new client is allowed, so the second client stays new
+		// and only the first one is let through.
+		send := func(ip string, want int64) {
+			conn, err := net.DialUDP("udp4", &net.UDPAddr{IP: net.ParseIP(ip)}, u.Addr().(*net.UDPAddr))
+			if err != nil {
+				t.Fatal("\tShould be able to dial a new UDP connection.", failed, err)
+			}
+			defer conn.Close()
+
+			conn.Write(make([]byte, 20))
+
+			for deadline := time.Now().Add(time.Second); u.Stats().Process+u.Stats().Drops < want; {
+				if time.Now().After(deadline) {
+					t.Fatalf("\tShould read the datagram from %s : %+v %s", ip, u.Stats(), failed)
+				}
+				time.Sleep(time.Millisecond)
+			}
+		}
+
+		send("127.0.0.1", 1)
+		send("127.0.0.2", 2)
+		send("127.0.0.1", 3)
+		send("127.0.0.2", 4)
+
+		u.Stop()
+
+		if sd := u.Stats(); sd.Process != 2 || sd.Drops != 2 || sd.NewSourceDrops != 2 {
+			t.Fatalf("\tShould drop the datagrams of the second new client : %+v %s", sd, failed)
+		}
+		t.Log("\tShould drop the datagrams of the second new client.", success)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if len(drops) != 2 || drops[0] != udp.DropNewSource || drops[1] != udp.DropNewSource {
+			t.Fatalf("\tShould report the drops as new sources : %v %s", drops, failed)
+		}
+		t.Log("\tShould report the drops as new sources.", success)
+	}
+}
+
+// TestUDPSession validates a client's datagrams share a session that is
+// closed once the client goes idle.
+func TestUDPSession(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	const timeout = 50 * time.Millisecond
+
+	t.Log("Given the need to keep state for a client across datagrams.")
+	{
+		sessions := make(chan *udp.Session, 10)
+		closed := make(chan *udp.Session, 10)
+
+		cfg := udp.Config{
+			NetType: "udp4",
+			Addr:    "127.0.0.1:0",
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler: funcReqHandler{
+				process: func(r *udp.Request) {
+					n, _ := r.Session.Value().(int)
+					r.Session.SetValue(n + 1)
+					sessions <- r.Session
+				},
+			},
+			RespHandler: udpRespHandler{},
+
+			SessionTimeout: timeout,
+			OnSessionClose: func(s *udp.Session) { closed <- s },
+		}
+
+		u, err := udp.New("TEST", cfg)
+		if err != nil {
+			t.Fatal("\tShould be able to create a new UDP listener.", failed, err)
+		}
+
+		if err := u.Start(); err != nil {
+			t.Fatal("\tShould be able to start the UDP listener.", failed, err)
+		}
+
+		conn, err := net.Dial("udp4", u.Addr().String())
+		if err != nil {
+			t.Fatal("\tShould be able to dial a new UDP connection.", failed, err)
+		}
+		defer conn.Close()
+
+		conn.Write(make([]byte, 20))
+		conn.Write(make([]byte, 20))
+
+		first, second := <-sessions, <-sessions
+		if first != second || first.Value() != 2 || first.UDPAddr.String() != conn.LocalAddr().String() {
+			t.Fatalf("\tShould share one session for the client : %v %s", first.Value(), failed)
+		}
+		t.Log("\tShould share one session for the client.", success)
+
+		select {
+		case s := <-closed:
+			if s != first {
+				t.Fatal("\tShould close the idle session.", failed)
+			}
+			t.Log("\tShould close the idle session.", success)
+		case <-time.After(10 * timeout):
+			t.Fatal("\tShould close the idle session.", failed)
+		}
+
+		conn.Write(make([]byte, 20))
+		if s := <-sessions; s == first || s.Value() != 1 {
+			t.Fatal("\tShould start a new session after the idle one closed.", failed)
+		}
+		t.Log("\tShould start a new session after the idle one closed.", success)
+
+		u.Stop()
+
+		select {
+		case <-closed:
+			t.Log("\tShould close the remaining session on stop.", success)
+		default:
+			t.Fatal("\tShould close the remaining session on stop.", failed)
+		}
+	}
+
+	t.Log("Given the need to run sessions with a timeout shorter than the sweep.")
+	{
+		cfg := udp.Config{
+			NetType: "udp4",
+			Addr:    "127.0.0.1:0",
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler:  udpReqHandler{},
+			RespHandler: udpRespHandler{},
+
+			SessionTimeout: time.Nanosecond,
+		}
+
+		u, err := udp.New("TEST", cfg)
+		if err != nil {
+			t.Fatal("\tShould be able to create a new UDP listener.", failed, err)
+		}
+
+		if err := u.Start(); err != nil {
+			t.Fatal("\tShould be able to start the UDP listener.", failed, err)
+		}
+		u.Stop()
+
+		t.Log("\tShould be able to start and stop the UDP listener.", success)
+	}
+}
+
+// TestUDPMiddleware validates the middleware wraps the handlers in order.
+func TestUDPMiddleware(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	t.Log("Given the need to wrap the handlers in middleware.")
+	{
+		tags := make(chan string, 10)
+
+		reqTag := func(tag string) func(udp.ReqHandler) udp.ReqHandler {
+			return func(h udp.ReqHandler) udp.ReqHandler {
+				return tagReqHandler{ReqHandler: h, tag: tag, tags: tags}
+			}
+		}
+
+		respTag := func(tag string) func(udp.RespHandler) udp.RespHandler {
+			return func(h udp.RespHandler) udp.RespHandler {
+				return tagRespHandler{RespHandler: h, tag: tag, tags: tags}
+			}
+		}
+
+		cfg := udp.Config{
+			NetType: "udp4",
+			Addr:    "127.0.0.1:0",
+
+			ConnHandler: udpConnHandler{},
+			ReqHandler:  udpReqHandler{},
+			RespHandler: udpRespHandler{},
+
+			ReqMiddleware:  []func(udp.ReqHandler) udp.ReqHandler{reqTag("req1"), reqTag("req2")},
+			RespMiddleware: []func(udp.RespHandler) udp.RespHandler{respTag("resp1"), respTag("resp2")},
+		}
+
+		u, err := udp.New("TEST", cfg)
+		if err != nil {
+			t.Fatal("\tShould be able to create a new UDP listener.", failed, err)
+		}
+
+		if err := u.Start(); err != nil {
+			t.Fatal("\tShould be able to start the UDP listener.", failed, err)
+		}
+		defer u.Stop()
+
+		conn, err := net.Dial("udp4", u.Addr().String())
+		if err != nil {
+			t.Fatal("\tShould be able to dial a new UDP connection.", failed, err)
+		}
+		defer conn.Close()
+
+		conn.Write(make([]byte, 20))
+
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		if _, err := conn.Read(make([]byte, 6)); err != nil {
+			t.Fatal("\tShould be able to read the response from the connection.", failed, err)
+		}
+		t.Log("\tShould still reach the wrapped handlers.", success)
+
+		var got []string
+		for i := 0; i < 4; i++ {
+			got = append(got, <-tags)
+		}
+
+		if want := "req1 req2 resp1 resp2"; strings.Join(got, " ") != want {
+			t.Fatalf("\tShould run the middleware in order : %v %s", got, failed)
+		}
+		t.Log("\tShould run the middleware in order.", success)
+	}
+}
+
+// TestUDPConn validates a listener can run over a connection provided by
+// the user.
+func TestUDPConn(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	t.Log("Given the need to run a listener over a scripted connection.")
+	{
+		client := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 5000}
+
+		conn := newFakeConn()
+		conn.reads <- fakeDatagram{client, make([]byte, 20)}
+
+		cfg := udp.Config{
+			ConnHandler: packetConnHandler{},
+			ReqHandler:  packetReqHandler{},
+			RespHandler: packetRespHandler{},
+
+			Conn: conn,
+		}
+
+		bad := cfg
+		bad.ConnHandler = udpConnHandler{}
+		if _, err := udp.New("TEST", bad); err != udp.ErrInvalidConnHandler {
+			t.Fatalf("\tShould reject a ConnHandler that can't bind the connection : %v %s", err, failed)
+		}
+		t.Log("\tShould reject a ConnHandler that can't bind the connection.", success)
+
+		u, err := udp.New("TEST", cfg)
+		if err != nil {
+			t.Fatal("\tShould be able to create a new UDP listener.", failed, err)
+		}
+
+		if err := u.Start(); err != nil {
+			t.Fatal("\tShould be able to start the UDP listener.", failed, err)
+		}
+
+		if u.Addr() != conn.LocalAddr() {
+			t.Fatalf("\tShould report the address of the connection : %v %s", u.Addr(), failed)
+		}
+		t.Log("\tShould report the address of the connection.", success)
+
+		w := <-conn.writes
+		if w.addr.String() != client.String() || string(w.data) != "GOT IT" {
+			t.Fatalf("\tShould write the response to the client : %v %q %s", w.addr, w.data, failed)
+		}
+		t.Log("\tShould write the response to the client.", success)
+
+		if err := u.Stop(); err != nil {
+			t.Fatal("\tShould be able to stop the UDP listener.", failed, err)
+		}
+
+		select {
+		case <-conn.closed:
+			t.Log("\tShould close the connection on Stop.", success)
+		default:
+			t.Fatal("\tShould close the connection on Stop.", failed)
+		}
+	}
+}
+
+// TestUDPConnEnd validates a listener whose connection ends on its own is
+// shut down the way Stop would.
+func TestUDPConnEnd(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	t.Log("Given the need to stop a listener once its connection ends.")
+	{
+		client := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 5000}
+
+		conn := newFakeConn()
+		conn.reads <- fakeDatagram{client, make([]byte, 20)}
+
+		stopped := make(chan error, 1)
+		closed := make(chan *udp.Session, 1)
+
+		cfg := udp.Config{
+			ConnHandler: packetConnHandler{},
+			ReqHandler:  packetReqHandler{},
+			RespHandler: packetRespHandler{},
+
+			Conn: conn,
+
+			SessionTimeout: time.Minute,
+			OnSessionClose: func(s *udp.Session) { closed <- s },
+			OnStop:         func(err error) { stopped <- err },
+		}
+
+		u, err := udp.New("TEST", cfg)
+		if err != nil {
+			t.Fatal("\tShould be able to create a new UDP listener.", failed, err)
+		}
+
+		if err := u.Start(); err != nil {
+			t.Fatal("\tShould be able to start the UDP listener.", failed, err)
+		}
+		ch := u.Subscribe()
+
+		// Wait for the response so the session exists, then end the
+		// connection.
+		<-conn.writes
+		conn.Close()
+
+		select {
+		case err := <-stopped:
+			if err != io.EOF {
+				t.Fatalf("\tShould call OnStop with the error that ended the listener : %v %s", err, failed)
+			}
+			t.Log("\tShould call OnStop with the error that ended the listener.", success)
+		case <-time.After(time.Second):
+			t.Fatal("\tShould call OnStop with the error that ended the listener.", failed)
+		}
+
+		if s := <-ch; s != udp.StateStopped || u.State() != udp.StateStopped {
+			t.Fatalf("\tShould move to the stopped state : %v %s", s, failed)
+		}
+		t.Log("\tShould move to the stopped state.", success)
+
+		select {
+		case s := <-closed:
+			if s.UDPAddr.String() != client.String() {
+				t.Fatalf("\tShould close the sessions : %v %s", s.UDPAddr, failed)
+			}
+			t.Log("\tShould close the sessions.", success)
+		default:
+			t.Fatal("\tShould close the sessions.", failed)
+		}
+
+		if err := u.Stop(); err == nil {
+			t.Fatal("\tShould report the listener as already stopped.", failed)
+		}
+		t.Log("\tShould report the listener as already stopped.", success)
+	}
+}
+
+// TestUDPContext validates the context of a request is canceled when the
+// request runs out of time or is abandoned by Stop.
+func TestUDPContext(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	tests := []struct {
+		name     string
+		request  time.Duration
+		shutdown time.Duration
+		err      error
+	}{
+		{"the request times out", 50 * time.Millisecond, 0, context.DeadlineExceeded},
+		{"Stop gives up on the request", 0, 50 * time.Millisecond, context.Canceled},
+	}
+
+	t.Log("Given the need to cancel the work of a request.")
+	{
+		for _, tt := range tests {
+			t.Logf("\tWhen %s", tt.name)
+			{
+				errs := make(chan error, 1)
+				started := make(chan struct{})
+
+				cfg := udp.Config{
+					NetType: "udp4",
+					Addr:    "127.0.0.1:0",
+
+					ConnHandler: udpConnHandler{},
+					ReqHandler: funcReqHandler{
+						process: func(r *udp.Request) {
+							close(started)
+							<-r.Context().Done()
+							errs <- r.Context().Err()
+						},
+					},
+					RespHandler: udpRespHandler{},
+
+					RequestTimeout:  tt.request,
+					ShutdownTimeout: tt.shutdown,
+				}
+
+				u, err := udp.New("TEST", cfg)
+				if err != nil {
+					t.Fatal("\t\tShould be able to create a new UDP listener.", failed, err)
+				}
+
+				if err := u.Start(); err != nil {
+					t.Fatal("\t\tShould be able to start the UDP listener.", failed, err)
+				}
+
+				conn, err := net.Dial("udp4", u.Addr().String())
+				if err != nil {
+					t.Fatal("\t\tShould be able to dial a new UDP connection.", failed, err)
+				}
+
+				conn.Write([]byte("work"))
+				<-started
+
+				if tt.shutdown > 0 {
+					u.Stop()
+				}
+
+				if err := <-errs; err != tt.err {
+					t.Errorf("\t\tShould cancel the context with %v : %v %s", tt.err, err, failed)
+				} else {
+					t.Logf("\t\tShould cancel the context with %v. %s", tt.err, success)
+				}
+
+				if tt.shutdown == 0 {
+					u.Stop()
+				}
+				conn.Close()
+			}
+		}
+	}
+}
+
+// traceKey is the context key of the trace added by a handler.
+type traceKey struct{}
+
+// TestUDPFromContext validates the request is recovered from a context
+// derived from its own.
+func TestUDPFromContext(t *testing.T) {
+	resetLog()
+	defer displayLog()
+
+	t.Log("Given the need to recover the request deep in a call graph.")
+	{
+		for _, timeout := range []time.Duration{0, time.Minute} {
+			t.Logf("\tWhen the request timeout is %v", timeout)
+			{
+				type result struct {
+					same  bool
+					addr  string
+					trace interface{}
+				}
+				results := make(chan result, 1)
+
+				// downstream only has the context to work with.
+				downstream := func(ctx context.Context) result {
+					r, ok := udp.FromContext(ctx)
+					if !ok {
+						return result{}
+					}
+					return result{addr: r.UDPAddr.String(), trace: ctx.Value(traceKey{})}
+				}
+
+				cfg := udp.Config{
+					NetType: "udp4",
+					Addr:    "127.0.0.1:0",
+
+					ConnHandler: udpConnHandler{},
+					ReqHandler: funcReqHandler{
+						process: func(r *udp.Request) {
+							ctx := context.WithValue(r.Context(), traceKey{}, "trace-1")
+							ctx, cancel := context.WithTimeout(ctx, time.Second)
+							defer cancel()
+
+							res := downstream(ctx)
+							got, _ := udp.FromContext(ctx)
+							res.same = got == r
+							results <- res
+						},
+					},
+					RespHandler: udpRespHandler{},
+
+					RequestTimeout: timeout,
+				}
+
+				u, err := udp.New("TEST", cfg)
+				if err != nil {
+					t.Fatal("\t\tShould be able to create a new UDP listener.", failed, err)
+				}
+
+				if err := u.Start(); err != nil {
+					t.Fatal("\t\tShould be able to start the UDP listener.", failed, err)
+				}
+
+				conn, err := net.Dial("udp4", u.Addr().String())
+				if err != nil {
+					t.Fatal("\t\tShould be able to dial a new UDP connection.", failed, err)
+				}
+
+				conn.Write([]byte("work"))
+
+				res := <-results
+				if !res.same || res.addr != conn.LocalAddr().String() {
+					t.Errorf("\t\tShould recover the request from a derived context : %+v %s", res, failed)
+				} else {
+					t.Log("\t\tShould recover the request from a derived context.", success)
+				}
+
+				if res.trace != "trace-1" {
+					t.Errorf("\t\tShould keep the values added by the handler : %v %s", res.trace, failed)
+				} else {
+					t.Log("\t\tShould keep the values added by the handler.", success)
+				}
+
+				conn.Close()
+				u.Stop()
+			}
+		}
+
+		if _, ok := udp.FromContext(context.Background()); ok {
+			t.Fatal("\tShould not find a request in another context.", failed)
+		}
+		t.Log("\tShould not find a request in another context.", success)
+	}
+}
+
 // Test generic UDP write timeout.
 func TestUDPWriteTimeout(t *testing.T) {
 	t.Log("Given the need to get a timeout error on UDP write.")
@@ -186,6 +3552,56 @@ func TestUDPWriteTimeout(t *testing.T) {
 
 // =============================================================================
 
+// fakeDatagram is a datagram read from or written to a fakeConn.
+type fakeDatagram struct {
+	addr net.Addr
+	data []byte
+}
+
+// fakeConn is a net.PacketConn that reads scripted datagrams and captures
+// the datagrams written to it.
+type fakeConn struct {
+	reads     chan fakeDatagram
+	writes    chan fakeDatagram
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// newFakeConn creates a connection with room for a few datagrams.
+func newFakeConn() *fakeConn {
+	return &fakeConn{
+		reads:  make(chan fakeDatagram, 10),
+		writes: make(chan fakeDatagram, 10),
+		closed: make(chan struct{}),
+	}
+}
+
+func (c *fakeConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	select {
+	case d := <-c.reads:
+		return copy(b, d.data), d.addr, nil
+	case <-c.closed:
+		return 0, nil, io.EOF
+	}
+}
+
+func (c *fakeConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	c.writes <- fakeDatagram{addr, append([]byte(nil), b...)}
+	return len(b), nil
+}
+
+func (c *fakeConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return nil
+}
+
+var fakeAddr = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 9999}
+
+func (c *fakeConn) LocalAddr() net.Addr                { return fakeAddr }
+func (c *fakeConn) SetDeadline(t time.Time) error      { return nil }
+func (c *fakeConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *fakeConn) SetWriteDeadline(t time.Time) error { return nil }
+
 // Success and failure markers.
 var (
 	success = "\u2713"
@@ -209,3 +3625,63 @@ func displayLog() {
 
 	logdash.WriteTo(os.Stdout)
 }
+
+// BenchmarkUDPRead measures the read path with and without the buffers
+// pooled by the package.
+func BenchmarkUDPRead(b *testing.B) {
+	done := make(chan struct{}, 1)
+	process := funcReqHandler{
+		process: func(r *udp.Request) {
+			done <- struct{}{}
+		},
+	}
+
+	benchmarks := []struct {
+		name    string
+		handler udp.ReqHandler
+	}{
+		{"Read", process},
+		{"ReadBuffer", bufferReqHandler{process}},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			cfg := udp.Config{
+				NetType: "udp4",
+				Addr:    "127.0.0.1:0",
+
+				ConnHandler: udpConnHandler{},
+				ReqHandler:  bm.handler,
+				RespHandler: udpRespHandler{},
+
+				MaxPacketSize: 20,
+			}
+
+			u, err := udp.New("TEST", cfg)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			if err := u.Start(); err != nil {
+				b.Fatal(err)
+			}
+			defer u.Stop()
+
+			conn, err := net.Dial("udp4", u.Addr().String())
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer conn.Close()
+
+			data := make([]byte, 20)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				conn.Write(data)
+				<-done
+			}
+		})
+	}
+}
@@ -0,0 +1,439 @@
+// Package udptest provides an in-memory transport for testing the handlers
+// of a udp listener without binding sockets.
+//
+// Conn is a net.PacketConn whose datagrams are injected by the test and
+// whose writes are captured for it. Server runs a listener over a Conn, so
+// the handlers under test see every datagram injected and the test sees
+// every response written.
+//
+// Pipe connects two Conns to each other, so two parties, such as a
+// listener and a client, exchange datagrams without the network. Faults
+// can drop or delay the datagrams delivered to a Conn to test how the
+// parties cope with an unreliable network.
+//
+// Handlers usually assert the reader and writer bound by their ConnHandler
+// to a *net.UDPConn. To run over both a socket and a Conn, assert them to
+// an interface with the ReadFromUDP and WriteToUDP methods instead, which
+// both types implement.
+package udptest
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ardanlabs/udp"
+)
+
+// Datagram is a datagram read from or written to a Conn. Addr is the
+// client that sent it or the destination it was written to.
+type Datagram struct {
+	Addr *net.UDPAddr
+	Data []byte
+}
+
+// Faults are the faults injected into the datagrams delivered to a Conn.
+// Delayed datagrams can be read in a different order than delivered, the
+// way they can over the network.
+type Faults struct {
+	Drop  func(dg Datagram) bool // Reports whether the datagram is lost.
+	Delay time.Duration          // How long a datagram takes to be readable.
+}
+
+// Conn is an in-memory net.PacketConn bound to an address.
+type Conn struct {
+	addr *net.UDPAddr
+	peer *Conn
+
+	in       []Datagram
+	out      []Datagram
+	faults   Faults
+	deadline time.Time
+	closed   bool
+	eof      bool          // The peer has been closed.
+	changed  chan struct{} // Closed and replaced whenever the state changes.
+	mu       sync.Mutex
+}
+
+// NewConn creates a connection bound to the address.
+func NewConn(addr *net.UDPAddr) *Conn {
+	return &Conn{
+		addr:    addr,
+		changed: make(chan struct{}),
+	}
+}
+
+// Pipe creates two connections bound to the addresses and connected to
+// each other. A datagram written by one to the address of the other is
+// read by the other, with the address of the writer as its source, and a
+// datagram written to any other address is captured for Written. Once one
+// is closed, reads of the other return io.EOF after the datagrams already
+// delivered to it have been read.
+func Pipe(a, b *net.UDPAddr) (*Conn, *Conn) {
+	ca, cb := NewConn(a), NewConn(b)
+	ca.peer, cb.peer = cb, ca
+
+	return ca, cb
+}
+
+// SetFaults sets the faults injected into the datagrams delivered from
+// now on, injected or written by the peer.
+func (c *Conn) SetFaults(f Faults) {
+	c.mu.Lock()
+	{
+		c.faults = f
+	}
+	c.mu.Unlock()
+}
+
+// notify wakes the routines waiting for the state to change. The caller
+// must hold the lock.
+func (c *Conn) notify() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// Inject queues a datagram from the client to be read. It reports false
+// when the datagram is lost, because of the faults or because the
+// connection is closed.
+func (c *Conn) Inject(from *net.UDPAddr, data []byte) bool {
+	return c.deliver(Datagram{Addr: from, Data: append([]byte(nil), data...)})
+}
+
+// deliver applies the faults to the datagram and queues it to be read,
+// reporting false when it is lost. A delayed datagram is lost if the
+// connection is closed before it is queued.
+func (c *Conn) deliver(dg Datagram) bool {
+	c.mu.Lock()
+	closed, faults := c.closed, c.faults
+	c.mu.Unlock()
+
+	if closed || (faults.Drop != nil && faults.Drop(dg)) {
+		return false
+	}
+
+	if faults.Delay > 0 {
+		time.AfterFunc(faults.Delay, func() { c.enqueue(dg) })
+		return true
+	}
+
+	c.enqueue(dg)
+	return true
+}
+
+// enqueue queues the datagram to be read unless the connection is closed.
+func (c *Conn) enqueue(dg Datagram) {
+	c.mu.Lock()
+	{
+		if !c.closed {
+			c.in = append(c.in, dg)
+			c.notify()
+		}
+	}
+	c.mu.Unlock()
+}
+
+// Written returns the datagrams written since the last call.
+func (c *Conn) Written() []Datagram {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	out := c.out
+	c.out = nil
+
+	return out
+}
+
+// ReadFromUDP waits for the next datagram delivered. Like a socket, the
+// part of the datagram that does not fit in b is lost. It returns io.EOF
+// once the peer is closed and every datagram delivered has been read.
+func (c *Conn) ReadFromUDP(b []byte) (int, *net.UDPAddr, error) {
+	for {
+		c.mu.Lock()
+
+		if c.closed {
+			c.mu.Unlock()
+			return 0, nil, c.opError("read", net.ErrClosed)
+		}
+
+		if len(c.in) > 0 {
+			dg := c.in[0]
+			c.in = c.in[1:]
+			c.mu.Unlock()
+
+			return copy(b, dg.Data), dg.Addr, nil
+		}
+
+		if c.eof {
+			c.mu.Unlock()
+			return 0, nil, io.EOF
+		}
+
+		deadline, changed := c.deadline, c.changed
+		c.mu.Unlock()
+
+		if deadline.IsZero() {
+			<-changed
+			continue
+		}
+
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return 0, nil, c.opError("read", os.ErrDeadlineExceeded)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-changed:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// ReadFrom implements the net.PacketConn interface.
+func (c *Conn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, addr, err := c.ReadFromUDP(b)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return n, addr, nil
+}
+
+// Read implements the io.Reader interface, dropping the address.
+func (c *Conn) Read(b []byte) (int, error) {
+	n, _, err := c.ReadFromUDP(b)
+	return n, err
+}
+
+// WriteToUDP delivers the datagram to the peer when written to its
+// address, and captures it for Written otherwise. Like a socket, writing
+// to a peer that is closed succeeds and the datagram is lost.
+func (c *Conn) WriteToUDP(b []byte, addr *net.UDPAddr) (int, error) {
+	if addr == nil {
+		return 0, c.opError("write", errors.New("missing address"))
+	}
+
+	data := append([]byte(nil), b...)
+
+	c.mu.Lock()
+
+	if c.closed {
+		c.mu.Unlock()
+		return 0, c.opError("write", net.ErrClosed)
+	}
+
+	// The lock of the peer is taken by deliver, so it is released first.
+	if c.peer != nil && addr.IP.Equal(c.peer.addr.IP) && addr.Port == c.peer.addr.Port {
+		c.mu.Unlock()
+		c.peer.deliver(Datagram{Addr: c.addr, Data: data})
+		return len(b), nil
+	}
+
+	c.out = append(c.out, Datagram{Addr: addr, Data: data})
+	c.mu.Unlock()
+
+	return len(b), nil
+}
+
+// WriteTo implements the net.PacketConn interface.
+func (c *Conn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	udpAddr, _ := addr.(*net.UDPAddr)
+	return c.WriteToUDP(b, udpAddr)
+}
+
+// Write is not supported since a datagram needs a destination.
+func (c *Conn) Write(b []byte) (int, error) {
+	return 0, c.opError("write", errors.New("missing address"))
+}
+
+// Close closes the connection. Blocked reads return an error, and reads
+// of the peer return io.EOF once it has read what was delivered to it.
+func (c *Conn) Close() error {
+	c.mu.Lock()
+
+	if c.closed {
+		c.mu.Unlock()
+		return c.opError("close", net.ErrClosed)
+	}
+
+	c.closed = true
+	c.notify()
+	c.mu.Unlock()
+
+	// The lock of the peer is taken after releasing this one, so closing
+	// both ends at once can't deadlock.
+	if c.peer != nil {
+		c.peer.mu.Lock()
+		{
+			c.peer.eof = true
+			c.peer.notify()
+		}
+		c.peer.mu.Unlock()
+	}
+
+	return nil
+}
+
+// LocalAddr implements the net.PacketConn interface.
+func (c *Conn) LocalAddr() net.Addr {
+	return c.addr
+}
+
+// SetDeadline implements the net.PacketConn interface.
+func (c *Conn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+// SetReadDeadline implements the net.PacketConn interface.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	{
+		c.deadline = t
+		c.notify()
+	}
+	c.mu.Unlock()
+
+	return nil
+}
+
+// SetWriteDeadline implements the net.PacketConn interface. Writes never
+// block, so it has no effect.
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+// opError describes a failed operation the way the net package does.
+func (c *Conn) opError(op string, err error) error {
+	return &net.OpError{Op: op, Net: "udp", Addr: c.addr, Err: err}
+}
+
+// ConnHandler binds a Conn, or a socket, as both the reader and the
+// writer.
+type ConnHandler struct{}
+
+// Bind implements the udp.ConnHandler interface.
+func (ConnHandler) Bind(listener *net.UDPConn) (io.Reader, io.Writer) {
+	return listener, listener
+}
+
+// BindPacketConn implements the udp.PacketConnHandler interface.
+func (ConnHandler) BindPacketConn(listener net.PacketConn) (io.Reader, io.Writer) {
+	conn := listener.(*Conn)
+	return conn, conn
+}
+
+// Server is a listener running over a Conn.
+type Server struct {
+	*udp.UDP
+	Conn *Conn
+
+	injected int64
+	done     int64
+	changed  chan struct{} // Closed and replaced whenever done changes.
+	mu       sync.Mutex
+}
+
+// Addr is the address the Conn of a Server is bound to.
+var Addr = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 9999}
+
+// NewServer creates and starts a listener for the configuration over a new
+// Conn bound to Addr. ConnHandler defaults to the one of this package, and
+// the OnDone callback of the configuration is still called.
+func NewServer(cfg udp.Config) (*Server, error) {
+	s := Server{
+		Conn:    NewConn(Addr),
+		changed: make(chan struct{}),
+	}
+
+	cfg.Conn = s.Conn
+	if cfg.ConnHandler == nil {
+		cfg.ConnHandler = ConnHandler{}
+	}
+
+	onDone := cfg.OnDone
+	cfg.OnDone = func() {
+		if onDone != nil {
+			onDone()
+		}
+
+		s.mu.Lock()
+		{
+			s.done++
+			close(s.changed)
+			s.changed = make(chan struct{})
+		}
+		s.mu.Unlock()
+	}
+
+	u, err := udp.New("udptest", cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.Start(); err != nil {
+		return nil, err
+	}
+
+	s.UDP = u
+
+	return &s, nil
+}
+
+// Inject queues a datagram from the client to be read by the listener. It
+// reports false when the datagram is lost, because of the faults set on
+// the Conn or because it is closed.
+func (s *Server) Inject(from *net.UDPAddr, data []byte) bool {
+	if !s.Conn.Inject(from, data) {
+		return false
+	}
+
+	s.mu.Lock()
+	{
+		s.injected++
+	}
+	s.mu.Unlock()
+
+	return true
+}
+
+// Wait waits for every datagram injected, and not lost to the faults, to
+// have been processed or dropped, for up to the timeout. The listener
+// signals each datagram it is done with, so Wait returns as soon as the
+// last one is.
+func (s *Server) Wait(timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		s.mu.Lock()
+		done, changed := s.done >= s.injected, s.changed
+		s.mu.Unlock()
+
+		if done {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			return errors.New("timed out waiting for the datagrams to be processed")
+		}
+	}
+}
+
+// Responses waits for the datagrams injected to be processed, for up to
+// the timeout, and returns the datagrams written since the last call.
+// Responses held by the write combiner are written after their flush
+// interval, so they may not be returned yet.
+func (s *Server) Responses(timeout time.Duration) ([]Datagram, error) {
+	if err := s.Wait(timeout); err != nil {
+		return nil, err
+	}
+
+	return s.Conn.Written(), nil
+}
@@ -0,0 +1,336 @@
+package udptest_test
+
+import (
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/udp"
+	"github.com/ardanlabs/udp/udptest"
+)
+
+// Success and failure markers.
+var (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+// packetConn is implemented by *net.UDPConn and *udptest.Conn.
+type packetConn interface {
+	ReadFromUDP(b []byte) (int, *net.UDPAddr, error)
+	WriteToUDP(b []byte, addr *net.UDPAddr) (int, error)
+}
+
+// echoReqHandler echoes each request upper cased.
+type echoReqHandler struct{}
+
+// Read implements the udp.ReqHandler interface.
+func (echoReqHandler) Read(reader io.Reader) (*net.UDPAddr, []byte, int, error) {
+	data := make([]byte, 64)
+	length, udpAddr, err := reader.(packetConn).ReadFromUDP(data)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	return udpAddr, data, length, nil
+}
+
+// Process implements the udp.ReqHandler interface.
+func (echoReqHandler) Process(r *udp.Request) {
+	data := make([]byte, r.Length)
+	for i, b := range r.Data[:r.Length] {
+		if b >= 'a' && b <= 'z' {
+			b -= 'a' - 'A'
+		}
+		data[i] = b
+	}
+
+	r.Reply(data)
+}
+
+// echoRespHandler writes the responses.
+type echoRespHandler struct{}
+
+// Write implements the udp.RespHandler interface.
+func (echoRespHandler) Write(r *udp.Response, writer io.Writer) error {
+	_, err := writer.(packetConn).WriteToUDP(r.Data[:r.Length], r.UDPAddr)
+	return err
+}
+
+// TestServer validates the handlers can be tested over the in-memory
+// transport.
+func TestServer(t *testing.T) {
+	t.Log("Given the need to test handlers without the network.")
+	{
+		s, err := udptest.NewServer(udp.Config{
+			ReqHandler:  echoReqHandler{},
+			RespHandler: echoRespHandler{},
+		})
+		if err != nil {
+			t.Fatal("\tShould be able to start the test server.", failed, err)
+		}
+		defer s.Stop()
+		t.Log("\tShould be able to start the test server.", success)
+
+		clients := []*net.UDPAddr{
+			{IP: net.IPv4(10, 0, 0, 1), Port: 1000},
+			{IP: net.IPv4(10, 0, 0, 2), Port: 2000},
+		}
+		for _, c := range clients {
+			s.Inject(c, []byte("hello "+c.IP.String()))
+		}
+
+		resps, err := s.Responses(time.Second)
+		if err != nil {
+			t.Fatal("\tShould process every datagram injected.", failed, err)
+		}
+		t.Log("\tShould process every datagram injected.", success)
+
+		if len(resps) != len(clients) {
+			t.Fatalf("\tShould capture a response per datagram : %d %s", len(resps), failed)
+		}
+		t.Log("\tShould capture a response per datagram.", success)
+
+		got := make(map[string]string)
+		for _, r := range resps {
+			got[r.Addr.String()] = string(r.Data)
+		}
+		for _, c := range clients {
+			if want := "HELLO " + c.IP.String(); got[c.String()] != want {
+				t.Fatalf("\tShould answer each client : %s %q %s", c, got[c.String()], failed)
+			}
+		}
+		t.Log("\tShould answer each client.", success)
+
+		if sd := s.Stats(); sd.Recv != 2 || sd.Process != 2 {
+			t.Fatalf("\tShould count the datagrams : %+v %s", sd, failed)
+		}
+		t.Log("\tShould count the datagrams.", success)
+	}
+}
+
+// TestServerDrops validates datagrams dropped by the listener are waited
+// for and produce no response.
+func TestServerDrops(t *testing.T) {
+	t.Log("Given the need to test datagrams the listener drops.")
+	{
+		var done int32
+
+		s, err := udptest.NewServer(udp.Config{
+			ReqHandler:  echoReqHandler{},
+			RespHandler: echoRespHandler{},
+			MaxIPv4Size: 4,
+			OnDone:      func() { atomic.AddInt32(&done, 1) },
+		})
+		if err != nil {
+			t.Fatal("\tShould be able to start the test server.", failed, err)
+		}
+		defer s.Stop()
+
+		client := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 1000}
+		s.Inject(client, []byte("too large"))
+		s.Inject(client, []byte("ok"))
+
+		resps, err := s.Responses(time.Second)
+		if err != nil {
+			t.Fatal("\tShould account for every datagram injected.", failed, err)
+		}
+		t.Log("\tShould account for every datagram injected.", success)
+
+		if len(resps) != 1 || string(resps[0].Data) != "OK" {
+			t.Fatalf("\tShould only answer the datagram accepted : %v %s", resps, failed)
+		}
+		t.Log("\tShould only answer the datagram accepted.", success)
+
+		if n := atomic.LoadInt32(&done); n != 2 {
+			t.Fatalf("\tShould still call the OnDone of the configuration : %d %s", n, failed)
+		}
+		t.Log("\tShould still call the OnDone of the configuration.", success)
+	}
+}
+
+// TestConn validates the in-memory connection behaves like a socket.
+func TestConn(t *testing.T) {
+	t.Log("Given the need to read and write over an in-memory connection.")
+	{
+		conn := udptest.NewConn(udptest.Addr)
+		client := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 1000}
+
+		conn.Inject(client, []byte("hello"))
+
+		b := make([]byte, 3)
+		n, addr, err := conn.ReadFrom(b)
+		if err != nil || string(b[:n]) != "hel" || addr.String() != client.String() {
+			t.Fatalf("\tShould read the datagram truncated to the buffer : %q %v %v %s", b[:n], addr, err, failed)
+		}
+		t.Log("\tShould read the datagram truncated to the buffer.", success)
+
+		conn.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+		_, _, err = conn.ReadFrom(b)
+		if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+			t.Fatalf("\tShould time out at the read deadline : %v %s", err, failed)
+		}
+		t.Log("\tShould time out at the read deadline.", success)
+
+		conn.SetReadDeadline(time.Time{})
+		if _, err := conn.WriteTo([]byte("bye"), client); err != nil {
+			t.Fatal("\tShould capture the datagram written.", failed, err)
+		}
+		if w := conn.Written(); len(w) != 1 || string(w[0].Data) != "bye" {
+			t.Fatalf("\tShould capture the datagram written : %v %s", w, failed)
+		}
+		t.Log("\tShould capture the datagram written.", success)
+
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			conn.Close()
+		}()
+		if _, _, err := conn.ReadFrom(b); err == nil {
+			t.Fatal("\tShould unblock the read when closed.", failed)
+		}
+		t.Log("\tShould unblock the read when closed.", success)
+	}
+}
+
+// TestPipe validates a listener and a client exchange datagrams over the
+// two ends of a pipe.
+func TestPipe(t *testing.T) {
+	t.Log("Given the need to connect two parties without the network.")
+	{
+		clientAddr := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 1000}
+		client, conn := udptest.Pipe(clientAddr, udptest.Addr)
+
+		stopped := make(chan error, 1)
+
+		u, err := udp.New("udptest", udp.Config{
+			ConnHandler: udptest.ConnHandler{},
+			ReqHandler:  echoReqHandler{},
+			RespHandler: echoRespHandler{},
+
+			Conn:   conn,
+			OnStop: func(err error) { stopped <- err },
+		})
+		if err != nil {
+			t.Fatal("\tShould be able to create the listener.", failed, err)
+		}
+
+		if err := u.Start(); err != nil {
+			t.Fatal("\tShould be able to start the listener.", failed, err)
+		}
+
+		if _, err := client.WriteToUDP([]byte("hello"), udptest.Addr); err != nil {
+			t.Fatal("\tShould be able to write to the listener.", failed, err)
+		}
+
+		b := make([]byte, 64)
+		n, addr, err := client.ReadFromUDP(b)
+		if err != nil || string(b[:n]) != "HELLO" || addr.String() != udptest.Addr.String() {
+			t.Fatalf("\tShould receive the response from the listener : %q %v %v %s", b[:n], addr, err, failed)
+		}
+		t.Log("\tShould receive the response from the listener.", success)
+
+		other := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 2000}
+		client.WriteToUDP([]byte("elsewhere"), other)
+		if w := client.Written(); len(w) != 1 || w[0].Addr.String() != other.String() {
+			t.Fatalf("\tShould capture the datagrams written to other addresses : %v %s", w, failed)
+		}
+		t.Log("\tShould capture the datagrams written to other addresses.", success)
+
+		client.Close()
+
+		select {
+		case err := <-stopped:
+			if err != io.EOF {
+				t.Fatalf("\tShould end the listener with io.EOF once the peer is closed : %v %s", err, failed)
+			}
+			t.Log("\tShould end the listener with io.EOF once the peer is closed.", success)
+		case <-time.After(time.Second):
+			t.Fatal("\tShould end the listener with io.EOF once the peer is closed.", failed)
+		}
+	}
+}
+
+// TestPipeClose validates the datagrams delivered before the peer is
+// closed are read before io.EOF.
+func TestPipeClose(t *testing.T) {
+	t.Log("Given the need to drain a pipe whose peer is closed.")
+	{
+		a, b := udptest.Pipe(udptest.Addr, &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 1000})
+
+		a.WriteToUDP([]byte("last"), b.LocalAddr().(*net.UDPAddr))
+		a.Close()
+
+		buf := make([]byte, 64)
+		if n, _, err := b.ReadFromUDP(buf); err != nil || string(buf[:n]) != "last" {
+			t.Fatalf("\tShould read the datagram delivered before the close : %q %v %s", buf[:n], err, failed)
+		}
+		t.Log("\tShould read the datagram delivered before the close.", success)
+
+		if _, _, err := b.ReadFromUDP(buf); err != io.EOF {
+			t.Fatalf("\tShould read io.EOF after the datagrams delivered : %v %s", err, failed)
+		}
+		t.Log("\tShould read io.EOF after the datagrams delivered.", success)
+
+		if _, err := b.WriteToUDP([]byte("lost"), udptest.Addr); err != nil {
+			t.Fatalf("\tShould accept writes to the closed peer : %v %s", err, failed)
+		}
+		t.Log("\tShould accept writes to the closed peer.", success)
+	}
+}
+
+// TestConnFaults validates datagrams are dropped and delayed by the
+// faults set on a connection.
+func TestConnFaults(t *testing.T) {
+	t.Log("Given the need to test over an unreliable network.")
+	{
+		s, err := udptest.NewServer(udp.Config{
+			ReqHandler:  echoReqHandler{},
+			RespHandler: echoRespHandler{},
+		})
+		if err != nil {
+			t.Fatal("\tShould be able to start the test server.", failed, err)
+		}
+		defer s.Stop()
+
+		const delay = 50 * time.Millisecond
+
+		s.Conn.SetFaults(udptest.Faults{
+			Drop:  func(dg udptest.Datagram) bool { return string(dg.Data) == "drop" },
+			Delay: delay,
+		})
+
+		client := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 1000}
+
+		if s.Inject(client, []byte("drop")) {
+			t.Fatal("\tShould report the datagram dropped.", failed)
+		}
+		t.Log("\tShould report the datagram dropped.", success)
+
+		start := time.Now()
+		if !s.Inject(client, []byte("keep")) {
+			t.Fatal("\tShould deliver the datagram kept.", failed)
+		}
+
+		resps, err := s.Responses(time.Second)
+		if err != nil {
+			t.Fatal("\tShould process the datagram kept.", failed, err)
+		}
+
+		if len(resps) != 1 || string(resps[0].Data) != "KEEP" {
+			t.Fatalf("\tShould only answer the datagram kept : %v %s", resps, failed)
+		}
+		t.Log("\tShould only answer the datagram kept.", success)
+
+		if elapsed := time.Since(start); elapsed < delay {
+			t.Fatalf("\tShould delay the datagram : %v %s", elapsed, failed)
+		}
+		t.Log("\tShould delay the datagram.", success)
+
+		if sd := s.Stats(); sd.Recv != 1 {
+			t.Fatalf("\tShould never read the datagram dropped : %+v %s", sd, failed)
+		}
+		t.Log("\tShould never read the datagram dropped.", success)
+	}
+}